@@ -0,0 +1,226 @@
+package fss3
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AsOfFS is a read-only fs.FS showing the tree of a versioned bucket as it
+// was at a point in time. Each object resolves to its latest version at or
+// before that time, and objects deleted by then are hidden. Files implement
+// io.Seeker, so the tree can be served with http.FileServer.
+type AsOfFS struct {
+	fss3 *FSS3
+	t    time.Time
+}
+
+// AsOf returns a read-only view of the tree as it was at t, built on
+// listings of the object versions. Each directory opened is resolved with a
+// recursive listing of the versions it contains.
+func (fss3 *FSS3) AsOf(t time.Time) *AsOfFS {
+	return &AsOfFS{fss3: fss3, t: t}
+}
+
+// live calls fn with the version of each object under prefix, or of the
+// object at prefix when exact is true, that was current at the time of the
+// view, until fn returns false.
+func (a *AsOfFS) live(prefix string, exact bool, fn func(objectInfo) bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := listObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    true,
+		WithVersions: true,
+	}
+	// Versions are listed by key, newest first.
+	resolved := ""
+	for obj := range a.fss3.listObjectsContext(ctx, &opts) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if exact && obj.Key != prefix {
+			if obj.Key > prefix {
+				break
+			}
+			continue
+		}
+		if obj.Key == resolved || obj.LastModified.After(a.t) {
+			continue
+		}
+		resolved = obj.Key
+		if !obj.IsDeleteMarker && !fn(obj) {
+			break
+		}
+	}
+	return nil
+}
+
+// file returns the version of the named file current at the time of the
+// view.
+func (a *AsOfFS) file(name string) (objectInfo, bool, error) {
+	var info objectInfo
+	found := false
+	if name == rootName {
+		return info, false, nil
+	}
+	err := a.live(a.fss3.nameToKey(name), true, func(obj objectInfo) bool {
+		info, found = obj, true
+		return false
+	})
+	return info, found, err
+}
+
+// dir returns the entries of the directory name at the time of the view.
+// Directories exist as long as they contain live objects.
+func (a *AsOfFS) dir(name string, entries bool) ([]fs.DirEntry, bool, error) {
+	fss3 := a.fss3
+	prefix := fss3.dirPrefix(name)
+	found := name == rootName
+	seen := make(map[string]bool)
+	var ents []fs.DirEntry
+	err := a.live(prefix, false, func(obj objectInfo) bool {
+		found = true
+		if !entries {
+			return false
+		}
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		elem, _, isDir := strings.Cut(rel, "/")
+		if !isDir {
+			if _, ok := fss3.markerDir(obj.Key); ok {
+				return true
+			}
+		}
+		child := fss3.joinName(name, fss3.cfg.KeyEncoder.Decode(elem))
+		if seen[child] {
+			return true
+		}
+		seen[child] = true
+		if isDir {
+			ents = append(ents, fss3.newEntry(child, objectInfo{Key: prefix + elem + "/"}, true))
+		} else {
+			ents = append(ents, fss3.newEntry(child, obj, false))
+		}
+		return true
+	})
+	sort.Slice(ents, func(i, j int) bool {
+		return ents[i].Name() < ents[j].Name()
+	})
+	return ents, found, err
+}
+
+// open resolves name, reading the entries of directories if entries is
+// true.
+func (a *AsOfFS) open(op, name string, entries bool) (*FileInfo, []fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	name = sanitizeName(name)
+	info, ok, err := a.file(name)
+	if err != nil {
+		return nil, nil, minioErrToPathErr(err)
+	}
+	if ok {
+		return a.fss3.newFileInfo(info), nil, nil
+	}
+	ents, ok, err := a.dir(name, entries)
+	if err != nil {
+		return nil, nil, minioErrToPathErr(err)
+	}
+	if !ok {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	dir := a.fss3.dirInfo(name, objectInfo{})
+	return a.fss3.newFileInfo(dir), ents, nil
+}
+
+// Open opens the named file or directory as it was at the time of the view.
+func (a *AsOfFS) Open(name string) (fs.File, error) {
+	fi, ents, err := a.open("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return &asOfDir{info: fi, ents: ents}, nil
+	}
+	a.fss3.fillMetadata(fi)
+	obj, err := a.fss3.getObject(fi.info.Key, &getObjectOptions{VersionID: fi.info.VersionID})
+	if err != nil {
+		return nil, minioErrToPathErr(err)
+	}
+	return &asOfFile{object: obj, info: fi}, nil
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory as it
+// was at the time of the view.
+func (a *AsOfFS) Stat(name string) (fs.FileInfo, error) {
+	fi, _, err := a.open("stat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	a.fss3.fillMetadata(fi)
+	return fi, nil
+}
+
+// ReadDir returns the entries of the named directory as it was at the time
+// of the view.
+func (a *AsOfFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fi, ents, err := a.open("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDirectory{name: name}}
+	}
+	return ents, nil
+}
+
+// asOfFile is a file of an AsOfFS.
+type asOfFile struct {
+	*object
+	info *FileInfo
+}
+
+func (f *asOfFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// asOfDir is a directory of an AsOfFS.
+type asOfDir struct {
+	info *FileInfo
+	ents []fs.DirEntry
+}
+
+func (d *asOfDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *asOfDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: ErrIsDirectory{name: d.info.Name()}}
+}
+
+func (d *asOfDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries of the directory, or all the remaining
+// ones if n <= 0.
+func (d *asOfDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		ents := d.ents
+		d.ents = nil
+		return ents, nil
+	}
+	if len(d.ents) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.ents) {
+		n = len(d.ents)
+	}
+	ents := d.ents[:n]
+	d.ents = d.ents[n:]
+	return ents, nil
+}
@@ -0,0 +1,441 @@
+package fss3
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy selects how Bisync resolves files changed on both sides.
+type ConflictPolicy int
+
+const (
+	// ConflictReport leaves conflicting files untouched and only reports them.
+	ConflictReport ConflictPolicy = iota
+	// ConflictNewerWins keeps the version with the latest modification time.
+	ConflictNewerWins
+	// ConflictKeepBoth keeps the remote version under the original name and
+	// the local version under the name with a suffix, on both sides.
+	ConflictKeepBoth
+)
+
+// DefaultBisyncStateFile is the name of the state file Bisync keeps in the
+// local directory when BisyncOptions.StateFile is empty.
+const DefaultBisyncStateFile = ".fss3-bisync.json"
+
+// BisyncOptions configures Bisync.
+type BisyncOptions struct {
+	// StateFile is the local file holding the state of the previous run.
+	// Defaults to DefaultBisyncStateFile inside the local directory.
+	StateFile string
+	// Conflict is the policy used to resolve conflicts.
+	Conflict ConflictPolicy
+	// ConflictSuffix is appended to the local version of a conflicting file
+	// with ConflictKeepBoth. Defaults to ".conflict". A counter is appended
+	// when the name is already taken.
+	ConflictSuffix string
+	// Include limits the sync to files matching one of these globs.
+	Include []string
+	// Exclude skips files matching any of these globs.
+	Exclude []string
+	// DryRun reports the changes without applying them.
+	DryRun bool
+}
+
+// BisyncReport lists the paths, relative to the synced directories, changed
+// by Bisync. In dry-run mode it lists the changes that would have been made.
+type BisyncReport struct {
+	Uploaded      []string
+	Downloaded    []string
+	DeletedLocal  []string
+	DeletedRemote []string
+	Conflicts     []string
+}
+
+// bisyncFile is the state of a file after a run.
+type bisyncFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	ETag    string    `json:"etag"`
+}
+
+// bisyncState is the snapshot of both sides saved after a run.
+type bisyncState struct {
+	Files map[string]bisyncFile `json:"files"`
+}
+
+// bisyncLocal is a local file seen during a run.
+type bisyncLocal struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// bisync holds the state of a single Bisync run.
+type bisync struct {
+	fss3     *FSS3
+	localDir string
+	dir      string
+	opts     *BisyncOptions
+	report   *BisyncReport
+	prev     map[string]bisyncFile
+	next     map[string]bisyncFile
+	local    map[string]bisyncLocal
+	remote   map[string]*FileInfo
+}
+
+func loadBisyncState(name string) (map[string]bisyncFile, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]bisyncFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state bisyncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.Files == nil {
+		state.Files = map[string]bisyncFile{}
+	}
+	return state.Files, nil
+}
+
+func saveBisyncState(name string, files map[string]bisyncFile) error {
+	data, err := json.Marshal(bisyncState{Files: files})
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Bisync synchronizes the local directory localDir and the directory dir of
+// remote in both directions. Changes are detected against the state saved by
+// the previous run; on the first run files present on only one side are
+// copied to the other and files present on both sides with different content
+// are conflicts.
+func Bisync(localDir string, remote *FSS3, dir string, opts *BisyncOptions) (*BisyncReport, error) {
+	if opts == nil {
+		opts = &BisyncOptions{}
+	}
+	stateFile := opts.StateFile
+	if stateFile == "" {
+		stateFile = filepath.Join(localDir, DefaultBisyncStateFile)
+	}
+	prev, err := loadBisyncState(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	b := &bisync{
+		fss3:     remote,
+		localDir: localDir,
+		dir:      sanitizeName(dir),
+		opts:     opts,
+		report:   &BisyncReport{},
+		prev:     prev,
+		next:     make(map[string]bisyncFile),
+		local:    make(map[string]bisyncLocal),
+		remote:   make(map[string]*FileInfo),
+	}
+	if err := b.scanLocal(stateFile); err != nil {
+		return b.report, err
+	}
+	if err := b.scanRemote(); err != nil {
+		return b.report, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for rel := range prev {
+		seen[rel] = true
+	}
+	for rel := range b.local {
+		seen[rel] = true
+	}
+	for rel := range b.remote {
+		seen[rel] = true
+	}
+	for rel := range seen {
+		if b.match(rel) {
+			names = append(names, rel)
+		}
+	}
+	sort.Strings(names)
+	for _, rel := range names {
+		if err := b.syncFile(rel); err != nil {
+			return b.report, err
+		}
+	}
+	if opts.DryRun {
+		return b.report, nil
+	}
+	return b.report, saveBisyncState(stateFile, b.next)
+}
+
+// match reports whether rel passes the include and exclude globs.
+func (b *bisync) match(rel string) bool {
+	opts := SyncOptions{Include: b.opts.Include, Exclude: b.opts.Exclude}
+	return opts.match(rel)
+}
+
+func (b *bisync) scanLocal(stateFile string) error {
+	absState, _ := filepath.Abs(stateFile)
+	err := filepath.WalkDir(b.localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == absState || abs == absState+".tmp" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(b.localDir, p)
+		if err != nil {
+			return err
+		}
+		b.local[filepath.ToSlash(rel)] = bisyncLocal{
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			mode:    info.Mode().Perm(),
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (b *bisync) scanRemote() error {
+	root := b.dir
+	err := b.fss3.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		b.remote[relPath(root, p)] = info.(*FileInfo)
+		return nil
+	})
+	if isNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// localName returns the local path of rel. Paths that would escape the
+// local directory, such as those of keys with ".." elements, are rejected.
+func (b *bisync) localName(rel string) (string, error) {
+	return localJoin(b.localDir, rel)
+}
+
+// conflictName returns the first name of the form rel+suffix, followed by a
+// counter if needed, that is used on neither side.
+func (b *bisync) conflictName(rel, suffix string) (string, error) {
+	for i := 0; ; i++ {
+		name := rel + suffix
+		if i > 0 {
+			name += "." + strconv.Itoa(i)
+		}
+		if _, ok := b.local[name]; ok {
+			continue
+		}
+		if _, ok := b.remote[name]; ok {
+			continue
+		}
+		local, err := b.localName(name)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Lstat(local); !errors.Is(err, fs.ErrNotExist) {
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+		return name, nil
+	}
+}
+
+func (b *bisync) syncFile(rel string) error {
+	if _, err := b.localName(rel); err != nil {
+		return err
+	}
+	prev, inPrev := b.prev[rel]
+	local, inLocal := b.local[rel]
+	remote, inRemote := b.remote[rel]
+
+	localChanged := inLocal && (!inPrev || local.size != prev.Size || !local.modTime.Equal(prev.ModTime))
+	localDeleted := !inLocal && inPrev
+	remoteChanged := inRemote && (!inPrev || remote.info.ETag != prev.ETag)
+	remoteDeleted := !inRemote && inPrev
+
+	switch {
+	case !localChanged && !localDeleted && !remoteChanged && !remoteDeleted:
+		b.next[rel] = prev
+		return nil
+	case localDeleted && remoteDeleted:
+		return nil
+	case (localChanged || localDeleted) && !remoteChanged && !remoteDeleted:
+		if localDeleted {
+			return b.deleteRemote(rel)
+		}
+		return b.upload(rel, rel)
+	case (remoteChanged || remoteDeleted) && !localChanged && !localDeleted:
+		if remoteDeleted {
+			return b.deleteLocal(rel)
+		}
+		return b.download(rel, rel)
+	case localChanged && remoteDeleted:
+		return b.upload(rel, rel)
+	case remoteChanged && localDeleted:
+		return b.download(rel, rel)
+	}
+
+	// Both sides changed. Identical content is not a conflict.
+	if local.size == remote.Size() && isMD5ETag(strings.Trim(remote.info.ETag, "\"")) {
+		name, err := b.localName(rel)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		sum, err := md5Sum(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if sum == strings.Trim(remote.info.ETag, "\"") {
+			b.next[rel] = bisyncFile{Size: local.size, ModTime: local.modTime, ETag: remote.info.ETag}
+			return nil
+		}
+	}
+
+	b.report.Conflicts = append(b.report.Conflicts, rel)
+	switch b.opts.Conflict {
+	case ConflictNewerWins:
+		if local.modTime.After(remote.ModTime()) {
+			return b.upload(rel, rel)
+		}
+		return b.download(rel, rel)
+	case ConflictKeepBoth:
+		suffix := b.opts.ConflictSuffix
+		if suffix == "" {
+			suffix = ".conflict"
+		}
+		conflict, err := b.conflictName(rel, suffix)
+		if err != nil {
+			return err
+		}
+		if !b.opts.DryRun {
+			from, _ := b.localName(rel)
+			to, _ := b.localName(conflict)
+			if err := os.Rename(from, to); err != nil {
+				return err
+			}
+		}
+		b.local[conflict] = local
+		if err := b.upload(conflict, conflict); err != nil {
+			return err
+		}
+		return b.download(rel, rel)
+	default:
+		if inPrev {
+			b.next[rel] = prev
+		}
+		return nil
+	}
+}
+
+func (b *bisync) upload(rel, dst string) error {
+	b.report.Uploaded = append(b.report.Uploaded, dst)
+	if b.opts.DryRun {
+		return nil
+	}
+	local := b.local[rel]
+	localName, err := b.localName(rel)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(localName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	name := b.fss3.joinName(b.dir, dst)
+	err = b.fss3.writeFrom(name, f, local.size, local.mode, local.modTime)
+	if err != nil {
+		return err
+	}
+	info, err := b.fss3.statObject(b.fss3.nameToKey(sanitizeName(name)), nil)
+	if err != nil {
+		return minioErrToPathErr(err)
+	}
+	b.next[dst] = bisyncFile{Size: local.size, ModTime: local.modTime, ETag: info.ETag}
+	return nil
+}
+
+func (b *bisync) download(rel, dst string) error {
+	b.report.Downloaded = append(b.report.Downloaded, dst)
+	if b.opts.DryRun {
+		return nil
+	}
+	remote := b.remote[rel]
+	name, err := b.localName(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), fs.ModePerm); err != nil {
+		return err
+	}
+	modTime := remote.ModTime()
+	err = b.fss3.download(remote.info.Key, name, remote.Mode().Perm(), modTime)
+	if err != nil {
+		return err
+	}
+	b.next[dst] = bisyncFile{Size: remote.Size(), ModTime: modTime, ETag: remote.info.ETag}
+	return nil
+}
+
+func (b *bisync) deleteRemote(rel string) error {
+	b.report.DeletedRemote = append(b.report.DeletedRemote, rel)
+	if b.opts.DryRun {
+		return nil
+	}
+	return b.fss3.Remove(b.fss3.joinName(b.dir, rel))
+}
+
+func (b *bisync) deleteLocal(rel string) error {
+	b.report.DeletedLocal = append(b.report.DeletedLocal, rel)
+	if b.opts.DryRun {
+		return nil
+	}
+	name, err := b.localName(rel)
+	if err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,209 @@
+package fss3
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheEntry is an object whose contents are stored in the disk cache.
+type cacheEntry struct {
+	info      objectInfo
+	path      string
+	validated time.Time
+	elem      *list.Element
+}
+
+// diskCache is a read-through LRU cache of object contents stored in a local
+// directory. Entries are keyed by object key and served only while the ETag
+// of the object matches.
+type diskCache struct {
+	dir string
+	max int64
+	ttl time.Duration
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*cacheEntry
+	lru     *list.List
+}
+
+// newDiskCache creates a cache in dir holding at most max bytes. Files left in
+// dir by a previous cache are removed.
+func newDiskCache(dir string, max int64, ttl time.Duration) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	for _, pattern := range []string{"*.cache", "*.tmp"} {
+		leftovers, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range leftovers {
+			os.Remove(name)
+		}
+	}
+	return &diskCache{
+		dir:     dir,
+		max:     max,
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+		lru:     list.New(),
+	}, nil
+}
+
+// fresh returns the info of the cached object at key if it was validated less
+// than the TTL ago.
+func (c *diskCache) fresh(key string) (objectInfo, bool) {
+	if c == nil || c.ttl <= 0 {
+		return objectInfo{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.validated) >= c.ttl {
+		return objectInfo{}, false
+	}
+	return e.info, true
+}
+
+// open opens the cached contents of the object described by info. Entries
+// with a different ETag are dropped.
+func (c *diskCache) open(info objectInfo) (*os.File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[info.Key]
+	if !ok {
+		return nil, false
+	}
+	if e.info.ETag != info.ETag {
+		c.drop(e)
+		return nil, false
+	}
+	f, err := os.Open(e.path)
+	if err != nil {
+		c.drop(e)
+		return nil, false
+	}
+	e.info = info
+	e.validated = time.Now()
+	c.lru.MoveToFront(e.elem)
+	return f, true
+}
+
+// fill stores the contents of r, the body of the object described by info,
+// and evicts the least recently used entries to stay within the size cap.
+func (c *diskCache) fill(info objectInfo, r io.Reader) (*os.File, error) {
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(info.Key))
+	name := filepath.Join(c.dir, hex.EncodeToString(sum[:])+".cache")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[info.Key]; ok {
+		c.drop(e)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		os.Remove(name)
+		return nil, err
+	}
+	e := &cacheEntry{info: info, path: name, validated: time.Now()}
+	e.elem = c.lru.PushFront(e)
+	c.entries[info.Key] = e
+	c.size += info.Size
+	for c.size > c.max && c.lru.Len() > 0 {
+		c.drop(c.lru.Back().Value.(*cacheEntry))
+	}
+	return f, nil
+}
+
+// drop removes e from the cache. Files already opened stay readable.
+func (c *diskCache) drop(e *cacheEntry) {
+	if c.entries[e.info.Key] != e {
+		return
+	}
+	delete(c.entries, e.info.Key)
+	c.lru.Remove(e.elem)
+	c.size -= e.info.Size
+	os.Remove(e.path)
+}
+
+// invalidate removes the object at key from the cache.
+func (c *diskCache) invalidate(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.drop(e)
+	}
+}
+
+// invalidatePrefix removes the objects whose keys start with prefix from the
+// cache.
+func (c *diskCache) invalidatePrefix(prefix string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.drop(e)
+		}
+	}
+}
+
+// openObject opens the contents of the object described by info, serving
+// them from the disk cache when it's enabled. Objects larger than the cache
+// are streamed from the bucket.
+func (fss3 *FSS3) openObject(info objectInfo) (io.ReadCloser, error) {
+	c := fss3.cache
+	// The contents must be the ones info describes.
+	opts := getObjectOptions{}
+	if err := opts.SetMatchETag(info.ETag); err != nil {
+		return nil, err
+	}
+	if c == nil || info.Size > c.max {
+		obj, err := fss3.getObject(info.Key, &opts)
+		if err != nil {
+			return nil, err
+		}
+		return fss3.verify(obj, &info), nil
+	}
+	if f, ok := c.open(info); ok {
+		return f, nil
+	}
+	obj, err := fss3.getObject(info.Key, &opts)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	f, err := c.fill(info, fss3.verify(obj, &info))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
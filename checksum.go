@@ -0,0 +1,180 @@
+package fss3
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ChecksumAlgorithm selects the checksum stored with uploaded objects.
+type ChecksumAlgorithm int
+
+const (
+	// ChecksumNone stores no checksum.
+	ChecksumNone ChecksumAlgorithm = iota
+	// ChecksumMD5 stores a MD5 digest, which is also sent as Content-MD5 so
+	// the server verifies the upload.
+	ChecksumMD5
+	// ChecksumCRC32C is a CRC-32 digest using the Castagnoli polynomial. It
+	// can be computed with Hash, and objects storing it are verified when
+	// read, but it can't be sent as a S3 additional checksum, so New
+	// rejects it in Config.Checksum.
+	ChecksumCRC32C
+	// ChecksumSHA256 is a SHA-256 digest. Like ChecksumCRC32C, it can't be
+	// used in Config.Checksum.
+	ChecksumSHA256
+)
+
+// String returns the name of the algorithm used in the "checksum" metadata.
+func (a ChecksumAlgorithm) String() string {
+	switch a {
+	case ChecksumMD5:
+		return "md5"
+	case ChecksumCRC32C:
+		return "crc32c"
+	case ChecksumSHA256:
+		return "sha256"
+	}
+	return "none"
+}
+
+func (a ChecksumAlgorithm) new() hash.Hash {
+	switch a {
+	case ChecksumMD5:
+		return md5.New()
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case ChecksumSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// storedChecksum returns the checksum stored in the metadata of info.
+func storedChecksum(info *objectInfo) (ChecksumAlgorithm, string, bool) {
+	name, sum, ok := strings.Cut(info.UserMetadata["Checksum"], ":")
+	if !ok {
+		return ChecksumNone, "", false
+	}
+	for _, a := range []ChecksumAlgorithm{ChecksumMD5, ChecksumCRC32C, ChecksumSHA256} {
+		if a.String() == name {
+			return a, sum, true
+		}
+	}
+	return ChecksumNone, "", false
+}
+
+// withChecksum computes the checksum of r with the algorithm of
+// Config.Checksum and stores it in opts. It returns a reader with the
+// contents of r, their size and a function releasing the resources used.
+// Readers that can't seek are spooled to a temporary file.
+func (fss3 *FSS3) withChecksum(r io.Reader, opts *putObjectOptions) (io.Reader, int64, func(), error) {
+	algo := fss3.cfg.Checksum
+	h := algo.new()
+	cleanup := func() {}
+	var size int64
+	var err error
+	if rs, ok := r.(io.ReadSeeker); ok {
+		var pos int64
+		pos, err = rs.Seek(0, io.SeekCurrent)
+		if err == nil {
+			size, err = io.Copy(h, rs)
+		}
+		if err == nil {
+			_, err = rs.Seek(pos, io.SeekStart)
+		}
+	} else {
+		var tmp *os.File
+		tmp, err = os.CreateTemp("", "fss3-*")
+		if err != nil {
+			return nil, 0, cleanup, err
+		}
+		cleanup = func() {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+		size, err = io.Copy(io.MultiWriter(tmp, h), r)
+		if err == nil {
+			_, err = tmp.Seek(0, io.SeekStart)
+		}
+		r = tmp
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, func() {}, err
+	}
+	opts.UserMetadata["checksum"] = algo.String() + ":" + hex.EncodeToString(h.Sum(nil))
+	opts.SendContentMd5 = algo == ChecksumMD5
+	return r, size, cleanup, nil
+}
+
+// verifyReader verifies the checksum of the contents it reads once the end
+// is reached.
+type verifyReader struct {
+	io.ReadCloser
+	name string
+	algo ChecksumAlgorithm
+	want string
+	h    hash.Hash
+}
+
+func (r *verifyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF {
+		if got := hex.EncodeToString(r.h.Sum(nil)); got != r.want {
+			return n, &fs.PathError{
+				Op:   "read",
+				Path: r.name,
+				Err:  ErrChecksumMismatch{name: r.name, algo: r.algo, want: r.want, got: got},
+			}
+		}
+	}
+	return n, err
+}
+
+// verify wraps the contents rc of the object described by info to verify
+// them against the stored checksum, if there is one.
+func (fss3 *FSS3) verify(rc io.ReadCloser, info *objectInfo) io.ReadCloser {
+	algo, sum, ok := storedChecksum(info)
+	if !ok {
+		return rc
+	}
+	return &verifyReader{ReadCloser: rc, name: fss3.keyToName(info.Key), algo: algo, want: sum, h: algo.new()}
+}
+
+// Hash returns the hex-encoded digest of the named file. The digest stored
+// on upload, or the ETag for MD5 when it's a MD5 digest, is returned without
+// downloading the file.
+func (fss3 *FSS3) Hash(name string, algo ChecksumAlgorithm) (string, error) {
+	name = sanitizeName(name)
+	h := algo.new()
+	if h == nil {
+		return "", &fs.PathError{Op: "hash", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fss3.statObject(fss3.nameToKey(name), nil)
+	if err != nil {
+		return "", minioErrToPathErr(err)
+	}
+	if stored, sum, ok := storedChecksum(&info); ok && stored == algo {
+		return sum, nil
+	}
+	if etag := strings.Trim(info.ETag, "\""); algo == ChecksumMD5 && isMD5ETag(etag) {
+		return etag, nil
+	}
+	f, err := fss3.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
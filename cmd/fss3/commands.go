@@ -0,0 +1,605 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aymanbagabas/fss3"
+)
+
+// stdout is where commands write their output.
+var stdout io.Writer = os.Stdout
+
+// errUsage is returned when a command is called with the wrong arguments.
+var errUsage = errors.New("invalid arguments")
+
+func newFlagSet(name string) *flag.FlagSet {
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: fss3 %s\n", commands[name].usage)
+		fset.PrintDefaults()
+	}
+	return fset
+}
+
+func parseMode(s string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q", s)
+	}
+	return fs.FileMode(mode), nil
+}
+
+func formatInfo(info fs.FileInfo, name string) string {
+	return fmt.Sprintf("%s %12d %s %s", info.Mode(), info.Size(), info.ModTime().Format(time.Stamp), name)
+}
+
+func runLs(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("ls")
+	long := fset.Bool("l", false, "use a long listing format")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	dir := fset.Arg(0)
+	ents, err := s3.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, ent := range ents {
+		name := ent.Name()
+		if ent.IsDir() {
+			name += "/"
+		}
+		if !*long {
+			fmt.Fprintln(stdout, name)
+			continue
+		}
+		info, err := ent.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, formatInfo(info, name))
+	}
+	return nil
+}
+
+func runTree(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("tree")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	root := path.Clean(strings.Trim(fset.Arg(0), "/"))
+	if _, err := s3.Stat(root); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, root)
+	var dirs, files int
+	if err := printTree(s3, root, "", &dirs, &files); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "\n%d directories, %d files\n", dirs, files)
+	return nil
+}
+
+// printTree prints the entries of dir, each line starting with prefix, and
+// counts them in dirs and files.
+func printTree(s3 *fss3.FSS3, dir, prefix string, dirs, files *int) error {
+	ents, err := s3.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for i, d := range ents {
+		branch, indent := "├── ", "│   "
+		if i == len(ents)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(stdout, "%s%s%s\n", prefix, branch, d.Name())
+		if !d.IsDir() {
+			*files++
+			continue
+		}
+		*dirs++
+		if err := printTree(s3, path.Join(dir, d.Name()), prefix+indent, dirs, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runCat(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("cat")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return errUsage
+	}
+	for _, name := range fset.Args() {
+		f, err := s3.Open(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(stdout, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPut(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("put")
+	modeFlag := fset.String("m", "", "object mode (octal), defaults to the local file mode")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		return errUsage
+	}
+	f, err := os.Open(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	mode := info.Mode().Perm()
+	if *modeFlag != "" {
+		mode, err = parseMode(*modeFlag)
+		if err != nil {
+			return err
+		}
+	}
+	return s3.WriteFrom(fset.Arg(1), f, mode)
+}
+
+func runGet(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("get")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		return errUsage
+	}
+	f, err := s3.Open(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: is a directory", fset.Arg(0))
+	}
+	mode := info.Mode().Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	out, err := os.OpenFile(fset.Arg(1), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, f)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(fset.Arg(1), info.ModTime(), info.ModTime())
+}
+
+func runCp(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("cp")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		return errUsage
+	}
+	return s3.Copy(fset.Arg(0), fset.Arg(1))
+}
+
+func runMv(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("mv")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		return errUsage
+	}
+	return s3.Rename(fset.Arg(0), fset.Arg(1))
+}
+
+func runRm(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("rm")
+	recursive := fset.Bool("r", false, "remove directories and their contents recursively")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return errUsage
+	}
+	for _, name := range fset.Args() {
+		var err error
+		if *recursive {
+			err = s3.RemoveAll(name)
+		} else {
+			err = s3.Remove(name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runMkdir(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("mkdir")
+	parents := fset.Bool("p", false, "make parent directories as needed")
+	modeFlag := fset.String("m", "777", "directory mode (octal)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return errUsage
+	}
+	mode, err := parseMode(*modeFlag)
+	if err != nil {
+		return err
+	}
+	for _, name := range fset.Args() {
+		if *parents {
+			err = s3.MkdirAll(name, mode)
+		} else {
+			err = s3.Mkdir(name, mode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runStat(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("stat")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return errUsage
+	}
+	for _, name := range fset.Args() {
+		info, err := s3.Stat(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "  Name: %s\n", info.Name())
+		fmt.Fprintf(stdout, "  Size: %d\n", info.Size())
+		fmt.Fprintf(stdout, "  Mode: %04o (%s)\n", info.Mode().Perm(), info.Mode())
+		fmt.Fprintf(stdout, "Modify: %s\n", info.ModTime().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runChmod(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("chmod")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() < 2 {
+		fset.Usage()
+		return errUsage
+	}
+	mode, err := parseMode(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, name := range fset.Args()[1:] {
+		info, err := s3.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := s3.Chmod(name, info.Mode().Type()|mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listFlag is a flag that can be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func runSync(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("sync")
+	var opts fss3.SyncOptions
+	var include, exclude listFlag
+	down := fset.Bool("down", false, "download the remote directory to the local directory instead")
+	checksum := fset.Bool("checksum", false, "compare MD5 checksums instead of modification times")
+	fset.BoolVar(&opts.Delete, "delete", false, "delete extraneous files from the destination")
+	fset.BoolVar(&opts.DryRun, "dry-run", false, "report changes without applying them")
+	fset.Var(&include, "include", "only sync files matching this glob (repeatable)")
+	fset.Var(&exclude, "exclude", "skip files matching this glob (repeatable)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		return errUsage
+	}
+	opts.Include = include
+	opts.Exclude = exclude
+	if *checksum {
+		opts.Compare = fss3.SyncSize | fss3.SyncChecksum
+	}
+
+	var report *fss3.SyncReport
+	var err error
+	if *down {
+		report, err = fss3.SyncToDir(s3, fset.Arg(1), fset.Arg(0), &opts)
+	} else {
+		report, err = fss3.Sync(os.DirFS(fset.Arg(0)), s3, fset.Arg(1), &opts)
+	}
+	if report != nil {
+		for _, p := range report.Copied {
+			fmt.Fprintf(stdout, "copy %s\n", p)
+		}
+		for _, p := range report.Deleted {
+			fmt.Fprintf(stdout, "delete %s\n", p)
+		}
+	}
+	return err
+}
+
+var conflictPolicies = map[string]fss3.ConflictPolicy{
+	"report":    fss3.ConflictReport,
+	"newer":     fss3.ConflictNewerWins,
+	"keep-both": fss3.ConflictKeepBoth,
+}
+
+func runBisync(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("bisync")
+	var opts fss3.BisyncOptions
+	var include, exclude listFlag
+	conflict := fset.String("conflict", "report", "conflict policy: report, newer or keep-both")
+	fset.StringVar(&opts.StateFile, "state", "", "state file, defaults to "+fss3.DefaultBisyncStateFile+" in the local directory")
+	fset.StringVar(&opts.ConflictSuffix, "suffix", "", "suffix of the local copy of conflicting files with -conflict keep-both")
+	fset.BoolVar(&opts.DryRun, "dry-run", false, "report changes without applying them")
+	fset.Var(&include, "include", "only sync files matching this glob (repeatable)")
+	fset.Var(&exclude, "exclude", "skip files matching this glob (repeatable)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		return errUsage
+	}
+	policy, ok := conflictPolicies[*conflict]
+	if !ok {
+		return fmt.Errorf("invalid conflict policy %q", *conflict)
+	}
+	opts.Conflict = policy
+	opts.Include = include
+	opts.Exclude = exclude
+
+	report, err := fss3.Bisync(fset.Arg(0), s3, fset.Arg(1), &opts)
+	if report != nil {
+		for _, p := range report.Uploaded {
+			fmt.Fprintf(stdout, "upload %s\n", p)
+		}
+		for _, p := range report.Downloaded {
+			fmt.Fprintf(stdout, "download %s\n", p)
+		}
+		for _, p := range report.DeletedRemote {
+			fmt.Fprintf(stdout, "delete remote %s\n", p)
+		}
+		for _, p := range report.DeletedLocal {
+			fmt.Fprintf(stdout, "delete local %s\n", p)
+		}
+		for _, p := range report.Conflicts {
+			fmt.Fprintf(stdout, "conflict %s\n", p)
+		}
+	}
+	return err
+}
+
+// sizeUnits are the binary size suffixes, in increasing order.
+var sizeUnits = []string{"K", "M", "G", "T", "P"}
+
+// parseSize parses a size in bytes with an optional K, M, G, T or P suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	num := strings.TrimSuffix(strings.ToUpper(s), "B")
+	for i, unit := range sizeUnits {
+		if strings.HasSuffix(num, unit) {
+			num = strings.TrimSuffix(num, unit)
+			mult = 1 << (10 * (i + 1))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+func runFind(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("find")
+	var q fss3.Query
+	var meta listFlag
+	fset.StringVar(&q.Name, "name", "", "match base names against this glob")
+	regex := fset.String("regex", "", "match paths relative to the root against this regular expression")
+	minSize := fset.String("min-size", "", "minimum size, such as 1G")
+	maxSize := fset.String("max-size", "", "maximum size, such as 512K")
+	newer := fset.Duration("newer", 0, "only entries modified within this duration, such as 168h")
+	older := fset.Duration("older", 0, "only entries modified before this duration ago")
+	modeFlag := fset.String("mode", "", "only entries with all these permission bits (octal)")
+	fset.StringVar(&q.ContentType, "content-type", "", "match content types against this glob, such as image/*")
+	fset.Var(&meta, "meta", "only entries with this key=value user metadata (repeatable)")
+	kind := fset.String("type", "f", "f for files or d for directories")
+	fset.StringVar(&q.StartAfter, "start-after", "", "skip paths up to and including this one")
+	long := fset.Bool("l", false, "use a long listing format")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() > 1 {
+		fset.Usage()
+		return errUsage
+	}
+	var err error
+	if *regex != "" {
+		if q.Regexp, err = regexp.Compile(*regex); err != nil {
+			return err
+		}
+	}
+	if *minSize != "" {
+		if q.MinSize, err = parseSize(*minSize); err != nil {
+			return err
+		}
+	}
+	if *maxSize != "" {
+		if q.MaxSize, err = parseSize(*maxSize); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	if *newer > 0 {
+		q.ModifiedAfter = now.Add(-*newer)
+	}
+	if *older > 0 {
+		q.ModifiedBefore = now.Add(-*older)
+	}
+	if *modeFlag != "" {
+		if q.Mode, err = parseMode(*modeFlag); err != nil {
+			return err
+		}
+	}
+	for _, kv := range meta {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("invalid metadata %q", kv)
+		}
+		if q.Metadata == nil {
+			q.Metadata = make(map[string]string)
+		}
+		q.Metadata[k] = v
+	}
+	switch *kind {
+	case "f":
+	case "d":
+		q.Dirs = true
+	default:
+		return fmt.Errorf("invalid type %q", *kind)
+	}
+
+	for ent, err := range s3.Find(fset.Arg(0), &q) {
+		if err != nil {
+			return err
+		}
+		if !*long {
+			fmt.Fprintln(stdout, ent.Path())
+			continue
+		}
+		info, err := ent.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, formatInfo(info, ent.Path()))
+	}
+	return nil
+}
+
+// formatSize formats a size in bytes with a binary suffix, like du -h.
+func formatSize(n int64) string {
+	if n < 1024 {
+		return strconv.FormatInt(n, 10)
+	}
+	size := float64(n)
+	unit := ""
+	for _, u := range sizeUnits {
+		if size < 1024 {
+			break
+		}
+		size /= 1024
+		unit = u
+	}
+	if size < 10 {
+		return fmt.Sprintf("%.1f%s", size, unit)
+	}
+	return fmt.Sprintf("%.0f%s", size, unit)
+}
+
+func runDu(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("du")
+	depth := fset.Int("d", 1, "break the usage down this many levels deep")
+	bytes := fset.Bool("b", false, "print sizes in bytes")
+	classes := fset.Bool("c", false, "break the total down by storage class")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() > 1 {
+		fset.Usage()
+		return errUsage
+	}
+	usage, err := s3.Usage(fset.Arg(0), *depth)
+	if err != nil {
+		return err
+	}
+	size := formatSize
+	if *bytes {
+		size = func(n int64) string { return strconv.FormatInt(n, 10) }
+	}
+	var show func(u *fss3.Usage)
+	show = func(u *fss3.Usage) {
+		for _, c := range u.Children {
+			show(c)
+		}
+		fmt.Fprintf(stdout, "%s\t%d\t%s\n", size(u.Size), u.Count, u.Path)
+	}
+	show(usage)
+	if *classes {
+		names := make([]string, 0, len(usage.ByStorageClass))
+		for class := range usage.ByStorageClass {
+			names = append(names, class)
+		}
+		sort.Strings(names)
+		for _, class := range names {
+			t := usage.ByStorageClass[class]
+			fmt.Fprintf(stdout, "%s\t%d\t%s\n", size(t.Size), t.Count, class)
+		}
+	}
+	return nil
+}
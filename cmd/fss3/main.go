@@ -0,0 +1,132 @@
+// Command fss3 inspects and manipulates buckets managed by fss3.
+//
+// Usage:
+//
+//	fss3 [flags] <command> [arguments]
+//
+// The connection flags default to the ACCESS_KEY_ID, SECRET_ACCESS_KEY,
+// ENDPOINT, REGION, BUCKET_NAME, DIR_FILE_NAME, USE_SSL and UMASK
+// environment variables.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/aymanbagabas/fss3"
+)
+
+// command is a fss3 sub-command.
+type command struct {
+	usage string
+	desc  string
+	run   func(s3 *fss3.FSS3, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ls":     {"ls [-l] [path]", "list directory contents", runLs},
+		"tree":   {"tree [path]", "list contents of directories in a tree-like format", runTree},
+		"cat":    {"cat path...", "print objects to the standard output", runCat},
+		"put":    {"put [-m mode] local remote", "upload a local file", runPut},
+		"get":    {"get remote local", "download an object to a local file", runGet},
+		"cp":     {"cp src dst", "copy objects", runCp},
+		"mv":     {"mv src dst", "move (rename) objects", runMv},
+		"rm":     {"rm [-r] path...", "remove objects", runRm},
+		"mkdir":  {"mkdir [-p] [-m mode] path...", "make directories", runMkdir},
+		"stat":   {"stat path...", "display object status", runStat},
+		"chmod":  {"chmod mode path...", "change object mode", runChmod},
+		"shell":  {"shell", "start an interactive shell", runShell},
+		"bisync": {"bisync [flags] local remote", "synchronize a local directory with the bucket in both directions", runBisync},
+		"sync":   {"sync [-down] [flags] local remote", "synchronize a local directory with the bucket", runSync},
+		"du":     {"du [-d depth] [-b] [-c] [path]", "display disk usage: size, file count and path", runDu},
+		"find":   {"find [flags] [path]", "search for files by name, size, time and metadata", runFind},
+	}
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+func envOctal(key string) int {
+	v, _ := strconv.ParseInt(os.Getenv(key), 8, 32)
+	return int(v)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: fss3 [flags] <command> [arguments]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-30s %s\n", commands[name].usage, commands[name].desc)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var cfg fss3.Config
+	var umask string
+	// The credentials are read from the environment after parsing so that
+	// the usage message doesn't print them as defaults.
+	flag.StringVar(&cfg.AccessKeyID, "access-key", "", "S3 access key ID (default $ACCESS_KEY_ID)")
+	flag.StringVar(&cfg.SecretAccessKey, "secret-key", "", "S3 secret access key (default $SECRET_ACCESS_KEY)")
+	flag.StringVar(&cfg.Endpoint, "endpoint", envOr("ENDPOINT", "s3.amazonaws.com"), "S3 endpoint")
+	flag.StringVar(&cfg.Region, "region", os.Getenv("REGION"), "S3 region")
+	flag.StringVar(&cfg.BucketName, "bucket", os.Getenv("BUCKET_NAME"), "bucket name")
+	flag.StringVar(&cfg.DirFileName, "dir-file-name", os.Getenv("DIR_FILE_NAME"), "directory marker file name")
+	flag.BoolVar(&cfg.UseSSL, "ssl", envBool("USE_SSL"), "use SSL")
+	flag.StringVar(&umask, "umask", fmt.Sprintf("%o", envOctal("UMASK")), "umask applied to new objects (octal)")
+	flag.Usage = usage
+	flag.Parse()
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv("ACCESS_KEY_ID")
+	}
+	if cfg.SecretAccessKey == "" {
+		cfg.SecretAccessKey = os.Getenv("SECRET_ACCESS_KEY")
+	}
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fss3: unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	mask, err := strconv.ParseInt(umask, 8, 32)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fss3: invalid umask %q\n", umask)
+		os.Exit(2)
+	}
+	cfg.Umask = int(mask)
+
+	s3, err := fss3.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fss3: %s\n", err)
+		os.Exit(1)
+	}
+	if err := cmd.run(s3, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "fss3 %s: %s\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aymanbagabas/fss3"
+	"golang.org/x/term"
+)
+
+// shellLocalArgs reports, for commands taking local paths or other
+// non-remote arguments, whether the i-th of n positional arguments must be
+// passed through without being resolved against the working directory.
+var shellLocalArgs = map[string]func(i, n int) bool{
+	"put":    func(i, n int) bool { return i == 0 },
+	"get":    func(i, n int) bool { return i == n-1 },
+	"chmod":  func(i, n int) bool { return i == 0 },
+	"sync":   func(i, n int) bool { return i == 0 },
+	"bisync": func(i, n int) bool { return i == 0 },
+}
+
+// shellOptionalPath are the commands whose path argument defaults to the
+// working directory in the shell.
+var shellOptionalPath = map[string]bool{
+	"tree": true,
+	"du":   true,
+	"find": true,
+}
+
+// shellValueFlags are the flags that consume the next argument.
+var shellValueFlags = map[string]bool{
+	"-m":            true,
+	"-include":      true,
+	"-exclude":      true,
+	"-conflict":     true,
+	"-state":        true,
+	"-suffix":       true,
+	"-name":         true,
+	"-regex":        true,
+	"-min-size":     true,
+	"-max-size":     true,
+	"-newer":        true,
+	"-older":        true,
+	"-mode":         true,
+	"-content-type": true,
+	"-meta":         true,
+	"-type":         true,
+	"-start-after":  true,
+	"-d":            true,
+}
+
+// shellMutating are the commands that invalidate cached listings.
+var shellMutating = map[string]bool{
+	"put":    true,
+	"cp":     true,
+	"mv":     true,
+	"rm":     true,
+	"mkdir":  true,
+	"chmod":  true,
+	"sync":   true,
+	"bisync": true,
+}
+
+// shell is an interactive session over a bucket.
+type shell struct {
+	s3    *fss3.FSS3
+	cwd   string
+	cache map[string][]fs.DirEntry
+}
+
+// resolve returns the bucket path of p relative to the working directory.
+func (sh *shell) resolve(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join("/", sh.cwd, p)
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// readDir returns the listing of dir, using the cache when possible.
+func (sh *shell) readDir(dir string) ([]fs.DirEntry, error) {
+	if ents, ok := sh.cache[dir]; ok {
+		return ents, nil
+	}
+	ents, err := sh.s3.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ents, func(i, j int) bool {
+		return ents[i].Name() < ents[j].Name()
+	})
+	sh.cache[dir] = ents
+	return ents, nil
+}
+
+func (sh *shell) cd(args []string) error {
+	dir := ""
+	if len(args) > 0 {
+		dir = sh.resolve(args[0])
+	}
+	info, err := sh.s3.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: not a directory", args[0])
+	}
+	sh.cwd = dir
+	return nil
+}
+
+func (sh *shell) ls(args []string) error {
+	fset := newFlagSet("ls")
+	fset.SetOutput(stdout)
+	long := fset.Bool("l", false, "use a long listing format")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	ents, err := sh.readDir(sh.resolve(fset.Arg(0)))
+	if err != nil {
+		return err
+	}
+	for _, ent := range ents {
+		name := ent.Name()
+		if ent.IsDir() {
+			name += "/"
+		}
+		if !*long {
+			fmt.Fprintln(stdout, name)
+			continue
+		}
+		info, err := ent.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, formatInfo(info, name))
+	}
+	return nil
+}
+
+// rewrite resolves the remote path arguments of a command against the
+// working directory.
+func (sh *shell) rewrite(name string, args []string) []string {
+	var positional []int
+	out := make([]string, len(args))
+	copy(out, args)
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") {
+			if shellValueFlags[args[i]] {
+				i++
+			}
+			continue
+		}
+		positional = append(positional, i)
+	}
+	isLocal := shellLocalArgs[name]
+	for n, i := range positional {
+		if isLocal != nil && isLocal(n, len(positional)) {
+			continue
+		}
+		out[i] = sh.resolve(args[i])
+	}
+	if len(positional) == 0 && shellOptionalPath[name] {
+		out = append(out, sh.resolve(""))
+	}
+	return out
+}
+
+// exec runs a single shell line.
+// It returns io.EOF when the session should end.
+func (sh *shell) exec(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	name, args := fields[0], fields[1:]
+	switch name {
+	case "exit", "quit":
+		return io.EOF
+	case "pwd":
+		fmt.Fprintln(stdout, "/"+sh.cwd)
+		return nil
+	case "cd":
+		return sh.cd(args)
+	case "ls":
+		return sh.ls(args)
+	case "refresh":
+		sh.cache = make(map[string][]fs.DirEntry)
+		return nil
+	case "help":
+		fmt.Fprintln(stdout, "Commands: cd, pwd, refresh, exit, help")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			if name != "shell" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stdout, "  %-30s %s\n", commands[name].usage, commands[name].desc)
+		}
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok || name == "shell" {
+		return fmt.Errorf("unknown command %q", name)
+	}
+	if shellMutating[name] {
+		sh.cache = make(map[string][]fs.DirEntry)
+	}
+	return cmd.run(sh.s3, sh.rewrite(name, args))
+}
+
+// complete completes the bucket path under the cursor.
+func (sh *shell) complete(line string, pos int) (string, int, []string) {
+	start := strings.LastIndexByte(line[:pos], ' ') + 1
+	word := line[start:pos]
+	dir, base := path.Split(word)
+	ents, err := sh.readDir(sh.resolve(dir))
+	if err != nil {
+		return line, pos, nil
+	}
+	var matches []string
+	for _, ent := range ents {
+		if strings.HasPrefix(ent.Name(), base) {
+			name := ent.Name()
+			if ent.IsDir() {
+				name += "/"
+			}
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) == 0 {
+		return line, pos, nil
+	}
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	completed := dir + prefix
+	newLine := line[:start] + completed + line[pos:]
+	return newLine, start + len(completed), matches
+}
+
+func (sh *shell) prompt() string {
+	return fmt.Sprintf("fss3:/%s> ", sh.cwd)
+}
+
+func runShell(s3 *fss3.FSS3, args []string) error {
+	fset := newFlagSet("shell")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	sh := &shell{
+		s3:    s3,
+		cache: make(map[string][]fs.DirEntry),
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if err := sh.exec(scanner.Text()); err == io.EOF {
+				return nil
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, sh.prompt())
+	stdout = t
+	defer func() { stdout = os.Stdout }()
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, matches := sh.complete(line, pos)
+		if len(matches) > 1 {
+			fmt.Fprintln(t, strings.Join(matches, "  "))
+		}
+		return newLine, newPos, true
+	}
+	for {
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sh.exec(line); err == io.EOF {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(t, "%s\n", err)
+		}
+		t.SetPrompt(sh.prompt())
+	}
+}
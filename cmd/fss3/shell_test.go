@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestShellResolve(t *testing.T) {
+	tests := []struct {
+		cwd, p, want string
+	}{
+		{"", "", ""},
+		{"", "a", "a"},
+		{"a", "", "a"},
+		{"a", "b/c", "a/b/c"},
+		{"a/b", "..", "a"},
+		{"a/b", "../../..", ""},
+		{"a", "/b", "b"},
+		{"a", "/", ""},
+	}
+	for _, tt := range tests {
+		sh := shell{cwd: tt.cwd}
+		if got := sh.resolve(tt.p); got != tt.want {
+			t.Errorf("resolve(%q) in %q = %q, want %q", tt.p, tt.cwd, got, tt.want)
+		}
+	}
+}
+
+func TestShellRewrite(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"cat", []string{"a", "/b"}, []string{"dir/a", "b"}},
+		{"cp", []string{"a", "../b"}, []string{"dir/a", "b"}},
+		{"put", []string{"-m", "0600", "local", "remote"}, []string{"-m", "0600", "local", "dir/remote"}},
+		{"get", []string{"remote", "local"}, []string{"dir/remote", "local"}},
+		{"chmod", []string{"0644", "a"}, []string{"0644", "dir/a"}},
+		{"tree", nil, []string{"dir"}},
+		{"du", []string{"-d", "1"}, []string{"-d", "1", "dir"}},
+		{"find", []string{"-name", "*.go"}, []string{"-name", "*.go", "dir"}},
+		{"find", []string{"-name", "*.go", "sub"}, []string{"-name", "*.go", "dir/sub"}},
+	}
+	sh := shell{cwd: "dir"}
+	for _, tt := range tests {
+		if got := sh.rewrite(tt.name, tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rewrite(%q, %q) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir/alpha":      {},
+		"dir/alps/file":  {},
+		"dir/beta":       {},
+		"dir/alps/other": {},
+	}
+	sh := shell{cwd: "dir", cache: make(map[string][]fs.DirEntry)}
+	for _, dir := range []string{"dir", "dir/alps"} {
+		ents, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sh.cache[dir] = ents
+	}
+	tests := []struct {
+		line    string
+		want    string
+		matches []string
+	}{
+		{"cat al", "cat alp", []string{"alpha", "alps/"}},
+		{"cat be", "cat beta", []string{"beta"}},
+		{"cat alps/f", "cat alps/file", []string{"file"}},
+		{"cat x", "cat x", nil},
+	}
+	for _, tt := range tests {
+		line, pos, matches := sh.complete(tt.line, len(tt.line))
+		if line != tt.want || pos != len(tt.want) || !reflect.DeepEqual(matches, tt.matches) {
+			t.Errorf("complete(%q) = %q, %d, %q, want %q, %d, %q", tt.line, line, pos, matches, tt.want, len(tt.want), tt.matches)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package fss3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// WriteOptions holds the conditions of a write, sent as a S3 conditional
+// PUT. ETags can be read with FileInfo.ETag.
+type WriteOptions struct {
+	// IfMatch writes only if the current ETag of the object is this one.
+	IfMatch string
+	// IfNoneMatch writes only if the current ETag of the object isn't this
+	// one. "*" writes only if the object doesn't exist.
+	IfNoneMatch string
+}
+
+// conditionsKey is the context key of the WriteOptions of a request.
+type conditionsKey struct{}
+
+// context returns ctx carrying the conditions of opts.
+func (opts *WriteOptions) context(ctx context.Context) context.Context {
+	if opts == nil || opts.IfMatch == "" && opts.IfNoneMatch == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, conditionsKey{}, opts)
+}
+
+// quoteETag quotes etag as expected in conditional headers.
+func quoteETag(etag string) string {
+	if etag == "*" || strings.HasPrefix(etag, "\"") {
+		return etag
+	}
+	return "\"" + etag + "\""
+}
+
+// conditionalTransport adds the conditions of the WriteOptions stored in
+// the request context to the requests writing objects: single PUTs, copies
+// and multipart upload completions.
+type conditionalTransport struct {
+	http.RoundTripper
+}
+
+func (t conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts, ok := req.Context().Value(conditionsKey{}).(*WriteOptions)
+	if !ok {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	query := req.URL.Query()
+	if req.Method == http.MethodPut && !query.Has("partNumber") || req.Method == http.MethodPost && query.Has("uploadId") {
+		req = req.Clone(req.Context())
+		if opts.IfMatch != "" {
+			req.Header.Set("If-Match", quoteETag(opts.IfMatch))
+		}
+		if opts.IfNoneMatch != "" {
+			req.Header.Set("If-None-Match", quoteETag(opts.IfNoneMatch))
+		}
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// writeErr converts an error writing the named object to a *fs.PathError.
+// Rejected conditions are reported as ErrPreconditionFailed.
+func writeErr(name string, err error) error {
+	switch errToRspErr(err).Code {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return &fs.PathError{
+			Op:   "write",
+			Path: name,
+			Err:  ErrPreconditionFailed{name: name},
+		}
+	}
+	return minioErrToPathErr(err)
+}
+
+// AtomicWriteFile writes data to the named object, creating any necessary
+// parent, like WriteFile. The data is first uploaded under a temporary name
+// in Config.TempPrefix and then copied over name server-side, so name is
+// only replaced once the whole data is stored. The copy only happens if the
+// conditions of opts hold, otherwise ErrPreconditionFailed is returned.
+//
+// The conditions are checked before the upload and sent with the copy.
+// Servers ignoring conditional headers on copies replace name even if it
+// changed in between.
+func (fss3 *FSS3) AtomicWriteFile(name string, data []byte, perm fs.FileMode, opts *WriteOptions) error {
+	name = sanitizeName(name)
+	if err := fss3.checkConditions(name, opts); err != nil {
+		return err
+	}
+	if err := fss3.mkdirParents(sanitizeName(path.Dir(name))); err != nil {
+		return err
+	}
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return err
+	}
+	// The temporary name ends with the base name to keep its content type.
+	tmp := fss3.joinName(sanitizeName(fss3.cfg.TempPrefix), hex.EncodeToString(suffix[:])+"."+path.Base(name))
+	err := fss3.putFile(tmp, bytes.NewReader(data), int64(len(data)), perm, time.Time{})
+	if err != nil {
+		return err
+	}
+	defer fss3.removeObject(fss3.nameToKey(tmp), nil)
+
+	ctx := opts.context(context.Background())
+	_, err = fss3.copyObjectContext(ctx, fss3.nameToKey(tmp), fss3.nameToKey(name), nil, nil)
+	if err != nil {
+		return writeErr(name, err)
+	}
+	return nil
+}
+
+// checkConditions returns ErrPreconditionFailed if the conditions of opts
+// don't hold for the named object.
+func (fss3 *FSS3) checkConditions(name string, opts *WriteOptions) error {
+	if opts == nil || opts.IfMatch == "" && opts.IfNoneMatch == "" {
+		return nil
+	}
+	info, err := fss3.statObject(fss3.nameToKey(name), nil)
+	if err != nil && !isNotExist(err) {
+		return minioErrToPathErr(err)
+	}
+	exists := err == nil
+	etag := quoteETag(info.ETag)
+	failed := opts.IfMatch != "" && (!exists || opts.IfMatch != "*" && quoteETag(opts.IfMatch) != etag) ||
+		opts.IfNoneMatch != "" && exists && (opts.IfNoneMatch == "*" || quoteETag(opts.IfNoneMatch) == etag)
+	if failed {
+		return &fs.PathError{Op: "write", Path: name, Err: ErrPreconditionFailed{name: name}}
+	}
+	return nil
+}
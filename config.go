@@ -1,5 +1,24 @@
 package fss3
 
+import (
+	"io/fs"
+	"time"
+)
+
+// DirMarker selects how directories are represented in the bucket.
+type DirMarker int
+
+const (
+	// MarkerFile represents a directory with a DirFileName object inside it.
+	MarkerFile DirMarker = iota
+	// MarkerSlash represents a directory with an empty object whose key is the
+	// directory name followed by a slash.
+	MarkerSlash
+	// MarkerNone stores nothing for directories. They are derived from the
+	// keys of the objects they contain.
+	MarkerNone
+)
+
 // Config is the configuration for the FSS3 client.
 type Config struct {
 	AccessKeyID     string
@@ -10,4 +29,70 @@ type Config struct {
 	BucketName      string
 	Umask           int
 	DirFileName     string
+	// KeyEncoder converts path elements to key elements and back.
+	// Defaults to a PercentEncoder reserving DirFileName.
+	//
+	// The default encoder decodes "%XX" sequences in existing keys, so
+	// objects written without it whose keys contain them, or whose names
+	// are DirFileName at the root, appear under a different name. Set a
+	// KeyEncoder whose methods return their argument unchanged to keep
+	// reading such buckets as before.
+	KeyEncoder KeyEncoder
+	// Layout selects the metadata and directory conventions used in the
+	// bucket. Layouts other than LayoutFSS3 override DirMarker.
+	Layout Layout
+	// DirMarker selects how directories are stored. Defaults to MarkerFile.
+	DirMarker DirMarker
+	// DirMode is the mode of directories without mode metadata, such as the
+	// directories derived from key prefixes. Defaults to 0755.
+	DirMode fs.FileMode
+	// FileMode is the mode of files without mode metadata with layouts other
+	// than LayoutFSS3. Defaults to 0644.
+	FileMode fs.FileMode
+	// SkipParentDirs disables creating the missing parent directories of
+	// written objects.
+	SkipParentDirs bool
+	// Concurrency is the maximum number of concurrent requests made by bulk
+	// operations and by ReadDir to fetch missing metadata. Defaults to 8.
+	Concurrency int
+	// CacheDir enables a read-through cache of object contents in this local
+	// directory. Cached contents are served while the ETag of the object
+	// matches. The directory is owned by the cache and its files are removed
+	// by New.
+	CacheDir string
+	// CacheSize is the maximum size of the cache in bytes. Objects larger
+	// than the cache aren't cached. Defaults to 1 GiB.
+	CacheSize int64
+	// CacheTTL is how long cached objects are served without revalidating
+	// their ETag with a HEAD request. Zero revalidates on every open.
+	CacheTTL time.Duration
+	// MetadataCacheTTL enables an in-memory cache of Stat results and
+	// directory listings, which are kept for this long. Mutations made
+	// through this FSS3 invalidate the affected entries.
+	MetadataCacheTTL time.Duration
+	// MetadataCacheSize is the maximum number of FileInfos and listings in
+	// the metadata cache. Defaults to 10000.
+	MetadataCacheSize int
+	// Checksum selects the checksum computed on upload, sent for the server
+	// to verify and stored in the object metadata. Objects with a stored
+	// checksum are verified when read, including by SyncToDir, CopyToDir and
+	// Bisync. Only ChecksumMD5 is supported; New returns
+	// ErrUnsupportedChecksum for the others. Defaults to ChecksumNone.
+	Checksum ChecksumAlgorithm
+	// LockPrefix is the directory holding the lock objects of Lock. It's
+	// left out of listings. Defaults to ".fss3-locks".
+	LockPrefix string
+	// SnapshotPrefix is the directory holding the manifests and copies of
+	// Snapshot. It's left out of listings. Defaults to ".fss3-snapshots".
+	SnapshotPrefix string
+	// Trash makes Remove and RemoveAll move what they delete under
+	// TrashPrefix instead, from where it can be restored with RestoreTrash
+	// until the trash is emptied.
+	Trash bool
+	// TrashPrefix is the directory holding deleted objects. It's left out of
+	// listings. Defaults to ".fss3-trash".
+	TrashPrefix string
+	// TempPrefix is the directory holding the temporary objects of
+	// AtomicWriteFile. It's left out of listings. Defaults to ".fss3-tmp".
+	TempPrefix string
 }
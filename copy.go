@@ -0,0 +1,158 @@
+package fss3
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// CopyFromFS copies the tree rooted at srcDir in src to the directory dstDir,
+// uploading up to Config.Concurrency files at a time. src can be any fs.FS,
+// such as os.DirFS, embed.FS or zip.Reader. File and directory modes and
+// modification times are preserved.
+//
+// Directories are created once, before the files are uploaded. A failed file
+// doesn't stop the copy; the errors of all the failed files are returned
+// joined together, each as a *fs.PathError.
+func (fss3 *FSS3) CopyFromFS(src fs.FS, srcDir, dstDir string) error {
+	dstDir = sanitizeName(dstDir)
+	var files []string
+	err := fs.WalkDir(src, srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := relPath(srcDir, p)
+		if !d.IsDir() {
+			files = append(files, rel)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return fss3.MkdirAll(dstDir, info.Mode())
+		}
+		return fss3.Mkdir(fss3.joinName(dstDir, rel), info.Mode())
+	})
+	if err != nil {
+		return err
+	}
+
+	return forEach(files, fss3.cfg.Concurrency, func(rel string) error {
+		p := path.Join(srcDir, rel)
+		f, err := src.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		err = fss3.putFile(fss3.joinName(dstDir, rel), f, info.Size(), info.Mode().Perm(), info.ModTime())
+		if err != nil {
+			return &fs.PathError{Op: "copy", Path: p, Err: err}
+		}
+		return nil
+	})
+}
+
+// CopyToDir copies the directory srcDir and everything it contains to the
+// local directory localPath, downloading up to Config.Concurrency objects at
+// a time. File and directory modes and modification times are preserved.
+// Directories are given theirs once all the files are copied, so read-only
+// directories can still be filled.
+//
+// A failed object doesn't stop the copy; the errors of all the failed objects
+// are returned joined together, each as a *fs.PathError. Objects whose keys
+// would resolve outside localPath, such as keys with ".." elements, fail with
+// fs.ErrInvalid.
+func (fss3 *FSS3) CopyToDir(srcDir, localPath string) error {
+	srcDir = sanitizeName(srcDir)
+	files, dirs, err := fss3.remoteEntries(srcDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(localPath, fs.ModePerm); err != nil {
+		return err
+	}
+	var errs []error
+	fail := func(rel string, err error) {
+		errs = append(errs, &fs.PathError{Op: "copy", Path: fss3.joinName(srcDir, rel), Err: err})
+	}
+	dirNames := make([]string, 0, len(dirs))
+	for rel := range dirs {
+		dirNames = append(dirNames, rel)
+	}
+	sort.Strings(dirNames)
+	created := make(map[string]string, len(dirNames))
+	for _, rel := range dirNames {
+		local, err := localJoin(localPath, rel)
+		if err == nil {
+			err = os.MkdirAll(local, fs.ModePerm)
+		}
+		if err != nil {
+			fail(rel, err)
+			continue
+		}
+		created[rel] = local
+	}
+
+	fileNames := make([]string, 0, len(files))
+	for rel := range files {
+		fileNames = append(fileNames, rel)
+	}
+	sort.Strings(fileNames)
+	err = forEach(fileNames, fss3.cfg.Concurrency, func(rel string) error {
+		fi := files[rel]
+		fss3.fillMetadata(fi)
+		local, err := localJoin(localPath, rel)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(local), fs.ModePerm)
+		}
+		if err == nil {
+			err = fss3.download(fi.info.Key, local, fi.Mode().Perm(), fi.ModTime())
+		}
+		if err != nil {
+			return &fs.PathError{Op: "copy", Path: fss3.joinName(srcDir, rel), Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// The deepest directories come first, so their parents are still
+	// writable.
+	for i := len(dirNames) - 1; i >= 0; i-- {
+		rel := dirNames[i]
+		local, ok := created[rel]
+		if !ok {
+			continue
+		}
+		// Directories without a marker have no metadata to fetch.
+		fi := dirs[rel]
+		if fi.info.ETag != "" {
+			if err := fss3.fillMetadata(fi); err != nil {
+				fail(rel, minioErrToPathErr(err))
+				continue
+			}
+		}
+		mode := fi.Mode().Perm()
+		if mode == 0 {
+			mode = fs.ModePerm
+		}
+		err := os.Chmod(local, mode)
+		if modTime := fi.ModTime(); err == nil && !modTime.IsZero() {
+			err = os.Chtimes(local, modTime, modTime)
+		}
+		if err != nil {
+			fail(rel, err)
+		}
+	}
+	return errors.Join(errs...)
+}
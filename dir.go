@@ -0,0 +1,113 @@
+package fss3
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// dirKey returns the key of the directory marker of name, or an empty string
+// if the directory has no marker.
+func (fss3 *FSS3) dirKey(name string) string {
+	switch fss3.cfg.DirMarker {
+	case MarkerSlash:
+		if name == rootName {
+			return ""
+		}
+		return fss3.nameToKey(name) + "/"
+	case MarkerNone:
+		return ""
+	}
+	if name == rootName {
+		return fss3.cfg.DirFileName
+	}
+	return fss3.nameToKey(name) + "/" + fss3.cfg.DirFileName
+}
+
+// markerDir returns the name of the directory key is the marker of.
+// Keys ending with a slash are recognized as markers with any DirMarker.
+func (fss3 *FSS3) markerDir(key string) (string, bool) {
+	if strings.HasSuffix(key, "/") {
+		return fss3.keyToName(key), true
+	}
+	if fss3.cfg.DirMarker == MarkerFile && (key == fss3.cfg.DirFileName || strings.HasSuffix(key, "/"+fss3.cfg.DirFileName)) {
+		return fss3.keyToName(path.Dir(key)), true
+	}
+	return "", false
+}
+
+// dirInfo fills in the directory attributes missing from info.
+func (fss3 *FSS3) dirInfo(name string, info objectInfo) objectInfo {
+	if info.Key == "" {
+		info.Key = fss3.dirPrefix(name)
+	}
+	mode, err := strconv.ParseUint(info.UserMetadata["Mode"], 8, 32)
+	if err != nil || !fs.FileMode(mode).IsDir() {
+		meta := make(minio.StringMap, len(info.UserMetadata)+1)
+		for k, v := range info.UserMetadata {
+			meta[k] = v
+		}
+		meta["Mode"] = fmt.Sprintf("%o", fss3.cfg.DirMode)
+		info.UserMetadata = meta
+	}
+	return info
+}
+
+// statDir gets info about the directory name. Directories without a marker
+// exist as long as they contain objects, except for MarkerFile where the
+// marker is required.
+func (fss3 *FSS3) statDir(name string) (objectInfo, error) {
+	if key := fss3.dirKey(name); key != "" {
+		info, err := fss3.statObject(key, nil)
+		if err == nil {
+			return fss3.dirInfo(name, info), nil
+		}
+		if fss3.cfg.DirMarker == MarkerFile || errToRspErr(err).Code != "NoSuchKey" {
+			return info, err
+		}
+	}
+	if name == rootName {
+		return fss3.dirInfo(name, objectInfo{}), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := listObjectsOptions{
+		Prefix:  fss3.dirPrefix(name),
+		MaxKeys: 1,
+	}
+	for obj := range fss3.listObjectsContext(ctx, &opts) {
+		if obj.Err != nil {
+			return objectInfo{}, obj.Err
+		}
+		return fss3.dirInfo(name, objectInfo{LastModified: obj.LastModified}), nil
+	}
+	return objectInfo{}, minio.ErrorResponse{
+		Code:    "NoSuchKey",
+		Message: "The specified key does not exist.",
+		Key:     name,
+	}
+}
+
+// dirExists reports whether the directory marker of name exists.
+// Directories without a marker always exist.
+func (fss3 *FSS3) dirExists(name string) (bool, error) {
+	key := fss3.dirKey(name)
+	if key == "" || fss3.knownDir(name) {
+		return true, nil
+	}
+	_, err := fss3.statObject(key, nil)
+	if err != nil {
+		if errToRspErr(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, minioErrToPathErr(err)
+	}
+	fss3.rememberDir(name)
+	return true, nil
+}
@@ -8,6 +8,13 @@ import (
 // ErrNoFileInfo is returned when a file info is not found.
 var ErrNoFileInfo = errors.New("fileInfo not found")
 
+// ErrLeaseLost is returned when a lock lease expired or was taken over.
+var ErrLeaseLost = errors.New("lease lost")
+
+// ErrUnsupportedChecksum is returned by New when Config.Checksum is an
+// algorithm that can't be sent to the server on upload.
+var ErrUnsupportedChecksum = errors.New("checksum not supported for uploads")
+
 // ErrInvalidHeader is returned when an invalid path is provided.
 type ErrInvalidHeader struct {
 	name  string
@@ -28,6 +35,15 @@ func (e ErrNotDirectory) Error() string {
 	return fmt.Sprintf("'%s' not a directory", e.name)
 }
 
+// ErrIsDirectory is returned when a path is a directory.
+type ErrIsDirectory struct {
+	name string
+}
+
+func (e ErrIsDirectory) Error() string {
+	return fmt.Sprintf("'%s' is a directory", e.name)
+}
+
 // ErrNotEmpty is returned when a directory is not empty.
 type ErrNotEmpty struct {
 	name string
@@ -36,3 +52,35 @@ type ErrNotEmpty struct {
 func (e ErrNotEmpty) Error() string {
 	return fmt.Sprintf("'%s' not empty", e.name)
 }
+
+// ErrChecksumMismatch is returned when the contents of an object don't match
+// the checksum stored on upload.
+type ErrChecksumMismatch struct {
+	name string
+	algo ChecksumAlgorithm
+	want string
+	got  string
+}
+
+func (e ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("'%s' %s checksum mismatch: expected %s, got %s", e.name, e.algo, e.want, e.got)
+}
+
+// ErrPreconditionFailed is returned when a conditional write is rejected
+// because the object changed.
+type ErrPreconditionFailed struct {
+	name string
+}
+
+func (e ErrPreconditionFailed) Error() string {
+	return fmt.Sprintf("'%s' precondition failed", e.name)
+}
+
+// ErrLocked is returned when a lock is held by another lease.
+type ErrLocked struct {
+	name string
+}
+
+func (e ErrLocked) Error() string {
+	return fmt.Sprintf("'%s' locked", e.name)
+}
@@ -1,6 +1,8 @@
 package fss3
 
 import (
+	"context"
+	"io"
 	"io/fs"
 	"strconv"
 	"strings"
@@ -9,6 +11,8 @@ import (
 
 // FileInfo implements fs.FileInfo.
 type FileInfo struct {
+	// name is the base name decoded from the key.
+	name    string
 	info    *objectInfo
 	size    int64
 	modTime time.Time
@@ -17,7 +21,8 @@ type FileInfo struct {
 // File implements fs.File.
 type File struct {
 	fs       *FS
-	obj      *object
+	name     string
+	obj      io.ReadCloser
 	fileInfo *FileInfo
 }
 
@@ -26,9 +31,20 @@ type DirEntry struct {
 	info *FileInfo
 }
 
+// newFileInfo returns the FileInfo of the object described by info.
+func (fss3 *FSS3) newFileInfo(info objectInfo) *FileInfo {
+	return &FileInfo{name: fss3.keyBaseName(info.Key), info: &info}
+}
+
 // Name returns the base name of the object extracted from its key.
 func (fi *FileInfo) Name() string {
-	return keyBaseName(fi.info.Key)
+	return fi.name
+}
+
+// ETag returns the entity tag of the object, without quotes. It changes
+// whenever the object is written and can be passed to WriteOptions.
+func (fi *FileInfo) ETag() string {
+	return strings.Trim(fi.info.ETag, "\"")
 }
 
 // Size returns the file size from the object
@@ -50,10 +66,14 @@ func (fi *FileInfo) Mode() fs.FileMode {
 	return fs.FileMode(mode)
 }
 
-// ModTime returns the last modification time of the object.
+// ModTime returns the modification time from the object metadata, or the
+// last modification time of the object if there is none.
 func (fi *FileInfo) ModTime() time.Time {
 	if fi.modTime.IsZero() {
 		fi.modTime = fi.info.LastModified
+		if mtime, err := parseModTime(fi.info.UserMetadata["Mtime"]); err == nil {
+			fi.modTime = mtime
+		}
 	}
 	return fi.modTime
 }
@@ -78,12 +98,23 @@ func (f *File) Stat() (fs.FileInfo, error) {
 }
 
 // Read reads up to len(b) bytes from the underlying object.
+// Directories can't be read.
 func (f *File) Read(b []byte) (int, error) {
+	if f.obj == nil {
+		return 0, &fs.PathError{
+			Op:   "read",
+			Path: f.name,
+			Err:  ErrIsDirectory{name: f.name},
+		}
+	}
 	return f.obj.Read(b)
 }
 
 // Close closes the object.
 func (f *File) Close() error {
+	if f.obj == nil {
+		return nil
+	}
 	return f.obj.Close()
 }
 
@@ -112,67 +143,73 @@ func (de *DirEntry) Info() (fs.FileInfo, error) {
 
 // ReadDir returns the entries from a directory.
 func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
-	var ents []fs.DirEntry
+	return f.ReadDirContext(context.Background(), n)
+}
+
+// ReadDirContext is like ReadDir but stops when ctx is done. The metadata
+// missing from the listing is fetched with up to Config.Concurrency
+// concurrent requests.
+func (f *File) ReadDirContext(ctx context.Context, n int) ([]fs.DirEntry, error) {
 	fStat, err := f.Stat()
 	if err != nil {
 		return nil, err
 	}
-
-	stat := fStat.(*FileInfo)
-	dirKey := stat.info.Key
-	if stat.IsDir() && (len(dirKey) > len(f.fs.fss3.cfg.DirFileName) && !strings.HasSuffix(dirKey, "/"+f.fs.fss3.cfg.DirFileName)) && dirKey != f.fs.fss3.cfg.DirFileName {
+	if !fStat.IsDir() {
 		return nil, ErrNotDirectory{name: fStat.Name()}
 	}
 
-	var prefix string
-	if dirKey == f.fs.fss3.cfg.DirFileName {
-		prefix = ""
-	} else {
-		prefix = dirKey[:len(dirKey)-len(f.fs.fss3.cfg.DirFileName)]
-	}
-
+	fss3 := f.fs.fss3
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	opts := listObjectsOptions{
-		Prefix:       prefix,
+		Prefix:       fss3.dirPrefix(f.name),
 		Recursive:    false,
 		WithMetadata: true,
 	}
 
-	counter := 0
-	for objInfo := range f.fs.fss3.listObjects(&opts) {
-		if n > 0 && counter >= n {
+	var infos []*FileInfo
+	var missing []int
+	for objInfo := range fss3.listObjectsContext(ctx, &opts) {
+		if n > 0 && len(infos) >= n {
 			break
 		}
 		if objInfo.Err != nil {
-			return ents, objInfo.Err
+			return nil, objInfo.Err
 		}
 		// Skip the current directory
-		if strings.HasSuffix(objInfo.Key, f.fs.fss3.cfg.DirFileName) {
+		if dir, ok := fss3.markerDir(objInfo.Key); ok && dir == f.name {
 			continue
 		}
 
 		oi := objInfo
-		key := oi.Key
-		fi := FileInfo{info: &oi}
-		// If directory, set directory key
-		if strings.HasSuffix(oi.Key, "/") {
-			key += f.fs.fss3.cfg.DirFileName
-		}
+		infos = append(infos, fss3.newFileInfo(oi))
 		// AWS S3 API doesn't return Metadata on listObjects
 		// We have to fetch the stats to get the metadata
 		// We also fetch the stats when it's a directory
 		// Reference: https://github.com/minio/minio-go/issues/1462
 		if len(oi.UserMetadata) == 0 || len(oi.Metadata) == 0 || strings.HasSuffix(oi.Key, "/") {
-			stat, err := f.fs.Stat(key)
-			if err != nil {
-				return ents, err
-			}
-			fi = *stat.(*FileInfo)
+			missing = append(missing, len(infos)-1)
 		}
-		ent := DirEntry{info: &fi}
-		ents = append(ents, &ent)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-		counter++
+	err = forEachContext(ctx, missing, fss3.cfg.Concurrency, func(i int) error {
+		stat, err := f.fs.Stat(fss3.keyToName(infos[i].info.Key))
+		if err != nil {
+			return err
+		}
+		infos[i] = stat.(*FileInfo)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	ents := make([]fs.DirEntry, len(infos))
+	for i, fi := range infos {
+		ents[i] = &DirEntry{info: fi}
+	}
 	return ents, nil
 }
@@ -0,0 +1,190 @@
+package fss3
+
+import (
+	"io/fs"
+	"iter"
+	"net/http"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"time"
+)
+
+// Query selects the entries returned by Find. Zero fields match everything.
+type Query struct {
+	// Name is a path.Match pattern matched against base names.
+	Name string
+	// Regexp is matched against paths relative to the root. The literal
+	// directories at the start of an anchored expression narrow the listing.
+	Regexp *regexp.Regexp
+	// MinSize and MaxSize bound the size of files in bytes. A zero MaxSize
+	// has no upper bound.
+	MinSize int64
+	MaxSize int64
+	// ModifiedAfter and ModifiedBefore bound the modification time.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	// Mode matches entries with all these permission bits set.
+	Mode fs.FileMode
+	// ContentType is a path.Match pattern matched against the content type,
+	// such as "image/*".
+	ContentType string
+	// Metadata matches entries with all these user metadata values.
+	Metadata map[string]string
+	// Dirs matches directories instead of files. Only directories with a
+	// marker can be found.
+	Dirs bool
+	// StartAfter skips the paths that sort before or equal to this path.
+	StartAfter string
+}
+
+// needsInfo reports whether q has predicates on metadata missing from
+// listings.
+func (q *Query) needsInfo() bool {
+	return !q.ModifiedAfter.IsZero() || !q.ModifiedBefore.IsZero() || q.Mode != 0 ||
+		q.ContentType != "" || len(q.Metadata) > 0
+}
+
+// prefix returns the directories at the start of the paths matching an
+// anchored Regexp.
+func (q *Query) prefix() string {
+	if q.Regexp == nil {
+		return ""
+	}
+	re, err := syntax.Parse(q.Regexp.String(), syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	lit := anchoredLiteral(re.Simplify())
+	if i := strings.LastIndex(lit, "/"); i >= 0 {
+		return lit[:i]
+	}
+	return ""
+}
+
+// anchoredLiteral returns the literal text following the begin-text anchor
+// at the start of re. Regexp.LiteralPrefix can't be used, as it stops at the
+// anchor.
+func anchoredLiteral(re *syntax.Regexp) string {
+	if re.Op != syntax.OpConcat || len(re.Sub) == 0 || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+	var b strings.Builder
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		b.WriteString(string(sub.Rune))
+	}
+	return b.String()
+}
+
+// matchListing reports whether the listed entry at rel matches the
+// predicates of q that don't need metadata.
+func (q *Query) matchListing(rel string, ent *Entry) bool {
+	if ent.dir != q.Dirs {
+		return false
+	}
+	if q.Name != "" {
+		if ok, _ := path.Match(q.Name, ent.Name()); !ok {
+			return false
+		}
+	}
+	if q.Regexp != nil && !q.Regexp.MatchString(rel) {
+		return false
+	}
+	size := ent.info.info.Size
+	return size >= q.MinSize && (q.MaxSize == 0 || size <= q.MaxSize)
+}
+
+// matchInfo reports whether fi matches the predicates of q on metadata.
+func (q *Query) matchInfo(fi *FileInfo) bool {
+	modTime := fi.ModTime()
+	if !q.ModifiedAfter.IsZero() && !modTime.After(q.ModifiedAfter) {
+		return false
+	}
+	if !q.ModifiedBefore.IsZero() && !modTime.Before(q.ModifiedBefore) {
+		return false
+	}
+	if fi.Mode().Perm()&q.Mode.Perm() != q.Mode.Perm() {
+		return false
+	}
+	if q.ContentType != "" {
+		if ok, _ := path.Match(q.ContentType, fi.info.ContentType); !ok {
+			return false
+		}
+	}
+	for k, v := range q.Metadata {
+		if fi.info.UserMetadata[http.CanonicalHeaderKey(k)] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns an iterator over the files, or directories, under root
+// matching q, in lexical order of their keys. The listing is narrowed to
+// the prefix and StartAfter of q, and the metadata missing from listings is
+// only fetched for the entries matching the other predicates. The listing
+// stops when the loop breaks. Errors are yielded in the second value.
+func (fss3 *FSS3) Find(root string, q *Query) iter.Seq2[*Entry, error] {
+	if q == nil {
+		q = &Query{}
+	}
+	return func(yield func(*Entry, error) bool) {
+		root := sanitizeName(root)
+		prefix := fss3.dirPrefix(root)
+		if p := q.prefix(); p != "" {
+			prefix = fss3.dirPrefix(fss3.joinName(root, p))
+		}
+		var marker string
+		if q.StartAfter != "" {
+			marker = fss3.nameToKey(sanitizeName(q.StartAfter))
+		}
+		for {
+			result, last, err := fss3.listPage(prefix, marker, "", 1000)
+			if err != nil {
+				yield(nil, minioErrToPathErr(err))
+				return
+			}
+			for _, obj := range result.Contents {
+				name, isDir := fss3.markerDir(obj.Key)
+				if !isDir {
+					name = fss3.keyToName(obj.Key)
+				}
+				if isDir && name == root {
+					continue
+				}
+				ent := fss3.newEntry(name, obj, isDir)
+				if !q.matchListing(relPath(root, name), ent) {
+					continue
+				}
+				if q.needsInfo() {
+					fi, err := ent.Info()
+					if isNotExist(err) {
+						// Removed since listed.
+						continue
+					}
+					if err != nil {
+						if !yield(nil, err) {
+							return
+						}
+						continue
+					}
+					if !q.matchInfo(fi.(*FileInfo)) {
+						continue
+					}
+				}
+				if !yield(ent, nil) {
+					return
+				}
+			}
+			// Pages only holding hidden objects are empty once filtered.
+			if !result.IsTruncated || last == "" {
+				return
+			}
+			marker = last
+		}
+	}
+}
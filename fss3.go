@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"io/fs"
+	"strings"
+	"sync"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -21,30 +23,89 @@ type removeObjectOptions = minio.RemoveObjectOptions
 type removeObjectsOptions = minio.RemoveObjectsOptions
 type copySrcOptions = minio.CopySrcOptions
 type copyDestOptions = minio.CopyDestOptions
-
-var dirFileName = "."
+type listBucketResult = minio.ListBucketResult
 
 // FSS3 represents an opened bucket.
 type FSS3 struct {
 	client *minio.Client
 	cfg    *Config
+
+	// dirs holds the directories known to exist.
+	dirsMu sync.Mutex
+	dirs   map[string]bool
+
+	// cache holds object contents on disk, if enabled.
+	cache *diskCache
+	// meta holds FileInfos and directory listings, if enabled.
+	meta *metaCache
+	// hiddenPrefixes are the key prefixes left out of listings.
+	hiddenPrefixes []string
 }
 
 // New creates a new FSS3 object
 func New(cfg Config) (*FSS3, error) {
+	if cfg.Checksum != ChecksumNone && cfg.Checksum != ChecksumMD5 {
+		return nil, ErrUnsupportedChecksum
+	}
 	creds := credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	transport, err := minio.DefaultTransport(cfg.UseSSL)
+	if err != nil {
+		return nil, err
+	}
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  creds,
-		Secure: cfg.UseSSL,
-		Region: cfg.Region,
+		Creds:     creds,
+		Secure:    cfg.UseSSL,
+		Region:    cfg.Region,
+		Transport: conditionalTransport{transport},
 	})
 	if cfg.DirFileName == "" {
 		cfg.DirFileName = "."
 	}
-	dirFileName = cfg.DirFileName
+	if cfg.KeyEncoder == nil {
+		cfg.KeyEncoder = PercentEncoder{Reserved: []string{cfg.DirFileName}}
+	}
+	cfg.DirMarker = cfg.Layout.dirMarker(cfg.DirMarker)
+	if cfg.FileMode == 0 {
+		cfg.FileMode = 0644
+	}
+	if cfg.DirMode == 0 {
+		cfg.DirMode = 0755
+	}
+	cfg.DirMode |= fs.ModeDir
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 8
+	}
+	if cfg.MetadataCacheSize <= 0 {
+		cfg.MetadataCacheSize = 10000
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = 1 << 30
+	}
 	fss3 := FSS3{
 		client: client,
 		cfg:    &cfg,
+		dirs:   make(map[string]bool),
+	}
+	if cfg.LockPrefix == "" {
+		cfg.LockPrefix = ".fss3-locks"
+	}
+	if cfg.SnapshotPrefix == "" {
+		cfg.SnapshotPrefix = ".fss3-snapshots"
+	}
+	if cfg.TrashPrefix == "" {
+		cfg.TrashPrefix = ".fss3-trash"
+	}
+	if cfg.TempPrefix == "" {
+		cfg.TempPrefix = ".fss3-tmp"
+	}
+	for _, prefix := range []string{cfg.LockPrefix, cfg.SnapshotPrefix, cfg.TrashPrefix, cfg.TempPrefix} {
+		fss3.hiddenPrefixes = append(fss3.hiddenPrefixes, fss3.dirPrefix(sanitizeName(prefix)))
+	}
+	if cfg.MetadataCacheTTL > 0 {
+		fss3.meta = newMetaCache(cfg.MetadataCacheTTL, cfg.MetadataCacheSize)
+	}
+	if cfg.CacheDir != "" && err == nil {
+		fss3.cache, err = newDiskCache(cfg.CacheDir, cfg.CacheSize, cfg.CacheTTL)
 	}
 	return &fss3, err
 }
@@ -56,10 +117,77 @@ func (fss3 *FSS3) FS() fs.FS {
 
 // listObjects lists all objects at the given prefix
 func (fss3 *FSS3) listObjects(opts *listObjectsOptions) <-chan objectInfo {
+	return fss3.listObjectsContext(context.Background(), opts)
+}
+
+// listObjectsContext lists all objects at the given prefix until ctx is done
+func (fss3 *FSS3) listObjectsContext(ctx context.Context, opts *listObjectsOptions) <-chan objectInfo {
 	if opts == nil {
 		opts = &listObjectsOptions{}
 	}
-	return fss3.client.ListObjects(context.Background(), fss3.cfg.BucketName, *opts)
+	objs := fss3.client.ListObjects(ctx, fss3.cfg.BucketName, *opts)
+	// Hidden objects are only listed under their own prefix.
+	hide := !fss3.hidden(opts.Prefix)
+	normalized := make(chan objectInfo)
+	go func() {
+		defer close(normalized)
+		for obj := range objs {
+			if obj.Err == nil && hide && fss3.hidden(obj.Key) {
+				continue
+			}
+			select {
+			case normalized <- fss3.normalize(obj, false):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return normalized
+}
+
+// hidden reports whether key is left out of listings.
+func (fss3 *FSS3) hidden(key string) bool {
+	for _, prefix := range fss3.hiddenPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listPage lists a single page of at most maxKeys objects at the given
+// prefix, starting after marker. It also returns the last key of the page
+// before hidden objects are left out, the marker of the next page: a page
+// can hold hidden objects only.
+func (fss3 *FSS3) listPage(prefix, marker, delimiter string, maxKeys int) (listBucketResult, string, error) {
+	core := minio.Core{Client: fss3.client}
+	result, err := core.ListObjects(fss3.cfg.BucketName, prefix, marker, delimiter, maxKeys)
+	if err != nil {
+		return result, "", err
+	}
+	last := result.NextMarker
+	if n := len(result.Contents); n > 0 && result.Contents[n-1].Key > last {
+		last = result.Contents[n-1].Key
+	}
+	if n := len(result.CommonPrefixes); n > 0 && result.CommonPrefixes[n-1].Prefix > last {
+		last = result.CommonPrefixes[n-1].Prefix
+	}
+	hide := !fss3.hidden(prefix)
+	contents := result.Contents[:0]
+	for _, obj := range result.Contents {
+		if !hide || !fss3.hidden(obj.Key) {
+			contents = append(contents, fss3.normalize(obj, false))
+		}
+	}
+	result.Contents = contents
+	prefixes := result.CommonPrefixes[:0]
+	for _, p := range result.CommonPrefixes {
+		if !hide || !fss3.hidden(p.Prefix) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	result.CommonPrefixes = prefixes
+	return result, last, nil
 }
 
 // getObject returns an Object for the given key
@@ -75,15 +203,28 @@ func (fss3 *FSS3) statObject(key string, opts *statObjectOptions) (objectInfo, e
 	if opts == nil {
 		opts = &statObjectOptions{}
 	}
-	return fss3.client.StatObject(context.Background(), fss3.cfg.BucketName, key, *opts)
+	info, err := fss3.client.StatObject(context.Background(), fss3.cfg.BucketName, key, *opts)
+	if err != nil {
+		return info, err
+	}
+	return fss3.normalize(info, true), nil
 }
 
 // putObject uploads a file to the given key
 func (fss3 *FSS3) putObject(key string, r io.Reader, size int64, opts *putObjectOptions) (uploadInfo, error) {
+	return fss3.putObjectContext(context.Background(), key, r, size, opts)
+}
+
+// putObjectContext uploads a file to the given key with the conditions
+// stored in ctx. The caches are invalidated once the upload is done, so
+// concurrent reads can't cache the previous object again.
+func (fss3 *FSS3) putObjectContext(ctx context.Context, key string, r io.Reader, size int64, opts *putObjectOptions) (uploadInfo, error) {
 	if opts == nil {
 		opts = &putObjectOptions{}
 	}
-	return fss3.client.PutObject(context.Background(), fss3.cfg.BucketName, key, r, size, *opts)
+	info, err := fss3.client.PutObject(ctx, fss3.cfg.BucketName, key, r, size, *opts)
+	fss3.invalidate(key)
+	return info, err
 }
 
 // removeObject removes a file for the given key
@@ -91,7 +232,9 @@ func (fss3 *FSS3) removeObject(key string, opts *removeObjectOptions) error {
 	if opts == nil {
 		opts = &removeObjectOptions{}
 	}
-	return fss3.client.RemoveObject(context.Background(), fss3.cfg.BucketName, key, *opts)
+	err := fss3.client.RemoveObject(context.Background(), fss3.cfg.BucketName, key, *opts)
+	fss3.invalidate(key)
+	return err
 }
 
 // removeObjects removes multiple files for the given object infos
@@ -102,8 +245,41 @@ func (fss3 *FSS3) removeObjects(objsCh <-chan objectInfo, opts *removeObjectsOpt
 	return fss3.client.RemoveObjects(context.Background(), fss3.cfg.BucketName, objsCh, *opts)
 }
 
+// maxCopySize is the largest object copied by a single CopyObject request.
+const maxCopySize = 5 << 30
+
+// copyObjectSize copies a file of the given size from src to dst. Files
+// larger than maxCopySize are copied in parts.
+func (fss3 *FSS3) copyObjectSize(srcKey, dstKey string, size int64, src *copySrcOptions, dst *copyDestOptions) (uploadInfo, error) {
+	if size <= maxCopySize {
+		return fss3.copyObject(srcKey, dstKey, src, dst)
+	}
+	srcOpts, dstOpts := fss3.copyOptions(srcKey, dstKey, src, dst)
+	info, err := fss3.client.ComposeObject(context.Background(), dstOpts, srcOpts)
+	if dstOpts.Bucket == fss3.cfg.BucketName {
+		fss3.invalidate(dstOpts.Object)
+	}
+	return info, err
+}
+
 // copyObject copies a file from src to dst
 func (fss3 *FSS3) copyObject(srcKey, dstKey string, src *copySrcOptions, dst *copyDestOptions) (uploadInfo, error) {
+	return fss3.copyObjectContext(context.Background(), srcKey, dstKey, src, dst)
+}
+
+// copyObjectContext copies a file from src to dst with the conditions stored
+// in ctx
+func (fss3 *FSS3) copyObjectContext(ctx context.Context, srcKey, dstKey string, src *copySrcOptions, dst *copyDestOptions) (uploadInfo, error) {
+	srcOpts, dstOpts := fss3.copyOptions(srcKey, dstKey, src, dst)
+	info, err := fss3.client.CopyObject(ctx, dstOpts, srcOpts)
+	if dstOpts.Bucket == fss3.cfg.BucketName {
+		fss3.invalidate(dstOpts.Object)
+	}
+	return info, err
+}
+
+// copyOptions returns src and dst with the bucket and keys defaulted.
+func (fss3 *FSS3) copyOptions(srcKey, dstKey string, src *copySrcOptions, dst *copyDestOptions) (copySrcOptions, copyDestOptions) {
 	if src == nil {
 		src = &copySrcOptions{
 			Bucket: fss3.cfg.BucketName,
@@ -128,5 +304,5 @@ func (fss3 *FSS3) copyObject(srcKey, dstKey string, src *copySrcOptions, dst *co
 	if dst.Object == "" {
 		dst.Object = dstKey
 	}
-	return fss3.client.CopyObject(context.Background(), *dst, *src)
+	return *src, *dst
 }
@@ -1,13 +1,26 @@
 package fss3
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 var (
@@ -199,7 +212,7 @@ func TestReadDir(t *testing.T) {
 }
 
 func TestWalkDir(t *testing.T) {
-	root := fss3.cfg.DirFileName
+	root := "."
 	_, err := fss3.Create("testfile")
 	if err != nil {
 		t.Fatal(err)
@@ -254,7 +267,7 @@ func TestRemoveAll(t *testing.T) {
 	if err != nil {
 		t.Errorf("remove all error: %s", err)
 	}
-	_, err = fss3.Stat(fss3.cfg.DirFileName)
+	_, err = fss3.Stat(".")
 	if err == nil {
 		t.Errorf("remove all error, expect not nil, but nil: %s", err)
 	}
@@ -280,6 +293,1453 @@ func TestChmod(t *testing.T) {
 	}
 }
 
+func TestCopy(t *testing.T) {
+	err := fss3.WriteFile("copy/src/file", []byte("hello copy"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fss3.RemoveAll("copy")
+	err = fss3.Copy("copy/src", "copy/dst")
+	if err != nil {
+		t.Fatalf("copy error: %s", err)
+	}
+	b, err := fss3.ReadFile("copy/dst/file")
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(b) != "hello copy" {
+		t.Errorf("copy error, expect 'hello copy', but got '%s'", b)
+	}
+}
+
+func TestRename(t *testing.T) {
+	err := fss3.WriteFile("rename/old", []byte("hello rename"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fss3.RemoveAll("rename")
+	err = fss3.Rename("rename/old", "rename/new")
+	if err != nil {
+		t.Fatalf("rename error: %s", err)
+	}
+	_, err = fss3.Stat("rename/old")
+	if err == nil {
+		t.Error("rename error, expect old to be removed")
+	}
+	info, err := fss3.Stat("rename/new")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != 0644 {
+		t.Errorf("rename error, expect mode 0644, but %o", info.Mode())
+	}
+}
+
+func TestSync(t *testing.T) {
+	modTime := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	src := fstest.MapFS{
+		"file":     {Data: []byte("hello sync"), Mode: 0600, ModTime: modTime},
+		"dir/file": {Data: []byte("hello dir"), Mode: 0644, ModTime: modTime},
+	}
+	defer fss3.RemoveAll("sync")
+	report, err := Sync(src, fss3, "sync", nil)
+	if err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	if len(report.Copied) != 2 {
+		t.Errorf("sync error, expect 2 copied files, but got %v", report.Copied)
+	}
+	info, err := fss3.Stat("sync/file")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != 0600 {
+		t.Errorf("sync error, expect mode 0600, but %o", info.Mode())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("sync error, expect mtime %s, but %s", modTime, info.ModTime())
+	}
+	report, err = Sync(src, fss3, "sync", nil)
+	if err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	if len(report.Copied) != 0 || len(report.Unchanged) != 2 {
+		t.Errorf("sync error, expect 2 unchanged files, but got %+v", report)
+	}
+	delete(src, "file")
+	report, err = Sync(src, fss3, "sync", &SyncOptions{Delete: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	if len(report.Deleted) != 1 || report.Deleted[0] != "file" {
+		t.Errorf("sync error, expect file to be deleted, but got %v", report.Deleted)
+	}
+}
+
+func TestBisync(t *testing.T) {
+	local := t.TempDir()
+	defer fss3.RemoveAll("bisync")
+	err := os.WriteFile(path.Join(local, "local"), []byte("hello local"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fss3.WriteFile("bisync/remote", []byte("hello remote"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Bisync(local, fss3, "bisync", nil)
+	if err != nil {
+		t.Fatalf("bisync error: %s", err)
+	}
+	if len(report.Uploaded) != 1 || len(report.Downloaded) != 1 {
+		t.Errorf("bisync error, expect 1 upload and 1 download, but got %+v", report)
+	}
+	b, err := os.ReadFile(path.Join(local, "remote"))
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(b) != "hello remote" {
+		t.Errorf("bisync error, expect 'hello remote', but got '%s'", b)
+	}
+
+	err = os.Remove(path.Join(local, "remote"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fss3.WriteFile("bisync/local", []byte("hello again"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = Bisync(local, fss3, "bisync", &BisyncOptions{Conflict: ConflictNewerWins})
+	if err != nil {
+		t.Fatalf("bisync error: %s", err)
+	}
+	if len(report.DeletedRemote) != 1 || report.DeletedRemote[0] != "remote" {
+		t.Errorf("bisync error, expect remote to be deleted, but got %v", report.DeletedRemote)
+	}
+	if len(report.Downloaded) != 1 || report.Downloaded[0] != "local" {
+		t.Errorf("bisync error, expect local to be downloaded, but got %v", report.Downloaded)
+	}
+}
+
+func TestCopyFromFS(t *testing.T) {
+	modTime := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	src := fstest.MapFS{
+		"src/a":     {Data: []byte("a"), Mode: 0600, ModTime: modTime},
+		"src/b/c":   {Data: []byte("c"), Mode: 0644, ModTime: modTime},
+		"src/b/d/e": {Data: []byte("e"), Mode: 0755, ModTime: modTime},
+	}
+	defer fss3.RemoveAll("bulk")
+	err := fss3.CopyFromFS(src, "src", "bulk")
+	if err != nil {
+		t.Fatalf("copy from fs error: %s", err)
+	}
+	info, err := fss3.Stat("bulk/b/d/e")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != 0755 {
+		t.Errorf("copy from fs error, expect mode 0755, but %o", info.Mode())
+	}
+
+	local := t.TempDir()
+	err = fss3.CopyToDir("bulk", local)
+	if err != nil {
+		t.Fatalf("copy to dir error: %s", err)
+	}
+	linfo, err := os.Stat(path.Join(local, "b/d/e"))
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if linfo.Mode() != 0755 {
+		t.Errorf("copy to dir error, expect mode 0755, but %o", linfo.Mode())
+	}
+	if !linfo.ModTime().Equal(modTime) {
+		t.Errorf("copy to dir error, expect mtime %s, but %s", modTime, linfo.ModTime())
+	}
+}
+
+func TestWriteKeepsParentMode(t *testing.T) {
+	err := fss3.Mkdir("keep", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fss3.RemoveAll("keep")
+	err = fss3.WriteFile("keep/file", []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := fss3.Stat("keep")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != fs.ModeDir|0700 {
+		t.Errorf("write error, expect parent mode %s, but %s", fs.ModeDir|0700, info.Mode())
+	}
+}
+
+func TestMarkerNone(t *testing.T) {
+	c := cfg
+	c.DirMarker = MarkerNone
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.RemoveAll("virtual")
+	err = s3.Mkdir("virtual", 0700)
+	if err != nil {
+		t.Fatalf("mkdir error: %s", err)
+	}
+	err = s3.WriteFile("virtual/dir/file", []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s3.statObject("virtual/dir/"+c.DirFileName, nil)
+	if err == nil {
+		t.Error("expect no directory marker")
+	}
+	info, err := s3.Stat("virtual/dir")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != s3.cfg.DirMode {
+		t.Errorf("stat error, expect mode %s, but %s", s3.cfg.DirMode, info.Mode())
+	}
+	ents, err := s3.ReadDir("virtual")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if len(ents) != 1 || ents[0].Name() != "dir" || !ents[0].IsDir() {
+		t.Errorf("read dir error, expect dir, but got %v", ents)
+	}
+	err = s3.Remove("virtual/dir")
+	if err == nil {
+		t.Error("remove error, expect not empty error")
+	}
+}
+
+func TestLayoutS3FS(t *testing.T) {
+	c := cfg
+	c.Layout = LayoutS3FS
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.RemoveAll("s3fs")
+	modTime := time.Unix(1622548800, 0)
+	err = s3.putFile("s3fs/file", strings.NewReader("hello"), 5, 0640, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s3.MkdirAll("s3fs/dir", 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := s3.client.StatObject(context.Background(), c.BucketName, "s3fs/file", minio.StatObjectOptions{})
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if raw.UserMetadata["Mode"] != "33184" {
+		t.Errorf("expect s3fs mode 33184, but %s", raw.UserMetadata["Mode"])
+	}
+	if raw.UserMetadata["Mtime"] != "1622548800" {
+		t.Errorf("expect s3fs mtime 1622548800, but %s", raw.UserMetadata["Mtime"])
+	}
+	_, err = s3.client.StatObject(context.Background(), c.BucketName, "s3fs/dir/", minio.StatObjectOptions{})
+	if err != nil {
+		t.Errorf("expect s3fs directory object: %s", err)
+	}
+	info, err := s3.Stat("s3fs/file")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != 0640 {
+		t.Errorf("expect mode 0640, but %s", info.Mode())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expect mtime %s, but %s", modTime, info.ModTime())
+	}
+	info, err = s3.Stat("s3fs/dir")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != fs.ModeDir|0750 {
+		t.Errorf("expect mode %s, but %s", fs.ModeDir|0750, info.Mode())
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	names := []string{
+		"my_file.txt",
+		"back\\slash",
+		"trailing space ",
+		"100%",
+		fss3.cfg.DirFileName + "x",
+	}
+	defer fss3.RemoveAll("encoding")
+	for _, name := range names {
+		_, err := fss3.Create(path.Join("encoding", name))
+		if err != nil {
+			t.Fatalf("create error: %s", err)
+		}
+	}
+	ents, err := fss3.ReadDir("encoding")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if len(ents) != len(names) {
+		t.Errorf("read dir error, expect %d entries, but got %d", len(names), len(ents))
+	}
+	for _, ent := range ents {
+		if !contains(names, ent.Name()) {
+			t.Errorf("read dir error, unexpected name %q", ent.Name())
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := cfg
+	c.CacheDir = t.TempDir()
+	c.CacheTTL = time.Minute
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.Remove("cached")
+	if err := s3.WriteFile("cached", []byte("first"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		data, err := s3.ReadFile("cached")
+		if err != nil {
+			t.Fatalf("read file error: %s", err)
+		}
+		if string(data) != "first" {
+			t.Errorf("read file error, expect %q, but got %q", "first", data)
+		}
+	}
+	if ents, _ := os.ReadDir(c.CacheDir); len(ents) != 1 {
+		t.Errorf("cache error, expect 1 cached file, but got %d", len(ents))
+	}
+	if err := s3.WriteFile("cached", []byte("second"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	data, err := s3.ReadFile("cached")
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("cache invalidation error, expect %q, but got %q", "second", data)
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	c := cfg
+	c.MetadataCacheTTL = time.Minute
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.RemoveAll("metacache")
+	if err := s3.WriteFile("metacache/a", []byte("a"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	ents, err := s3.ReadDir("metacache")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if len(ents) != 1 {
+		t.Errorf("read dir error, expect 1 entry, but got %d", len(ents))
+	}
+	if err := s3.WriteFile("metacache/b", []byte("bb"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	ents, err = s3.ReadDir("metacache")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if len(ents) != 2 {
+		t.Errorf("cache invalidation error, expect 2 entries, but got %d", len(ents))
+	}
+	info, err := s3.Stat("metacache/b")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Size() != 2 {
+		t.Errorf("stat error, expect size 2, but got %d", info.Size())
+	}
+	if err := s3.Chmod("metacache/b", 0600); err != nil {
+		t.Fatalf("chmod error: %s", err)
+	}
+	info, err = s3.Stat("metacache/b")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode() != 0600 {
+		t.Errorf("cache invalidation error, expect mode %s, but got %s", fs.FileMode(0600), info.Mode())
+	}
+	// Writing to a directory known to exist keeps the listings above it.
+	if _, err := s3.ReadDir("."); err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if _, err := s3.ReadDir("metacache"); err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if err := s3.WriteFile("metacache/c", []byte("c"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if _, ok := s3.meta.list("."); !ok {
+		t.Errorf("cache invalidation error, expect the root listing to be kept")
+	}
+	if _, ok := s3.meta.list("metacache"); ok {
+		t.Errorf("cache invalidation error, expect the metacache listing to be dropped")
+	}
+}
+
+func TestReadDirContext(t *testing.T) {
+	defer fss3.RemoveAll("readdirctx")
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		if err := fss3.Mkdir(path.Join("readdirctx", name), 0755); err != nil {
+			t.Fatalf("mkdir error: %s", err)
+		}
+	}
+	ents, err := fss3.ReadDirContext(context.Background(), "readdirctx")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if len(ents) != len(names) {
+		t.Fatalf("read dir error, expect %d entries, but got %d", len(names), len(ents))
+	}
+	for i, ent := range ents {
+		if ent.Name() != names[i] || !ent.IsDir() {
+			t.Errorf("read dir error, expect directory %q, but got %q", names[i], ent.Name())
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fss3.ReadDirContext(ctx, "readdirctx"); err == nil {
+		t.Error("read dir error, expect an error with a canceled context")
+	}
+}
+
+func TestWalkDirOrder(t *testing.T) {
+	defer fss3.RemoveAll("walk")
+	for _, name := range []string{"walk/b/file", "walk/a/skipped/file", "walk/a/file", "walk/c"} {
+		if err := fss3.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	expect := []string{"walk", "walk/a", "walk/a/file", "walk/a/skipped", "walk/b", "walk/b/file", "walk/c"}
+	var got []string
+	err := fss3.WalkDir("walk", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, p)
+		if p == "walk/a/skipped" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk dir error: %s", err)
+	}
+	if strings.Join(got, ",") != strings.Join(expect, ",") {
+		t.Errorf("walk dir error, expect %v, but got %v", expect, got)
+	}
+}
+
+func TestIterators(t *testing.T) {
+	defer fss3.RemoveAll("iter")
+	for _, name := range []string{"iter/a/b/file", "iter/a/file", "iter/c"} {
+		if err := fss3.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	var names []string
+	for ent, err := range fss3.Entries("iter") {
+		if err != nil {
+			t.Fatalf("entries error: %s", err)
+		}
+		names = append(names, ent.Name())
+	}
+	if strings.Join(names, ",") != "a,c" {
+		t.Errorf("entries error, expect [a c], but got %v", names)
+	}
+
+	var paths []string
+	for ent, err := range fss3.Walk("iter") {
+		if err != nil {
+			t.Fatalf("walk error: %s", err)
+		}
+		paths = append(paths, ent.Path())
+	}
+	expect := []string{"iter/a", "iter/a/b", "iter/a/b/file", "iter/a/file", "iter/c"}
+	if strings.Join(paths, ",") != strings.Join(expect, ",") {
+		t.Errorf("walk error, expect %v, but got %v", expect, paths)
+	}
+
+	for ent := range fss3.Walk("iter") {
+		if ent.Path() != "iter/a" {
+			t.Errorf("walk error, expect iter/a first, but got %s", ent.Path())
+		}
+		break
+	}
+
+	for _, err := range fss3.Entries("iter/missing") {
+		if !isNotExist(err) {
+			t.Errorf("entries error, expect not exist, but got %v", err)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	defer fss3.RemoveAll("list")
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names {
+		if err := fss3.WriteFile(path.Join("list", name), []byte(name), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	var got []string
+	opts := ListOptions{PageSize: 2}
+	for {
+		ents, token, err := fss3.List("list", &opts)
+		if err != nil {
+			t.Fatalf("list error: %s", err)
+		}
+		for _, ent := range ents {
+			got = append(got, ent.Name())
+		}
+		if token == "" {
+			break
+		}
+		opts.Token = token
+	}
+	if strings.Join(got, ",") != strings.Join(names, ",") {
+		t.Errorf("list error, expect %v, but got %v", names, got)
+	}
+
+	ents, _, err := fss3.List("list", &ListOptions{StartAfter: "list/c"})
+	if err != nil {
+		t.Fatalf("list error: %s", err)
+	}
+	if len(ents) != 2 || ents[0].Name() != "d" {
+		t.Errorf("list error, expect entries after c, but got %d", len(ents))
+	}
+
+	if _, _, err := fss3.List("list", &ListOptions{Token: "invalid"}); err == nil {
+		t.Error("list error, expect an error with an invalid token")
+	}
+}
+
+func TestFind(t *testing.T) {
+	defer fss3.RemoveAll("find")
+	files := map[string]int{
+		"find/data/a.parquet":  10,
+		"find/data/b.parquet":  100,
+		"find/data/c.csv":      100,
+		"find/other/d.parquet": 100,
+	}
+	for name, size := range files {
+		if err := fss3.WriteFile(name, make([]byte, size), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	q := Query{
+		Name:          "*.parquet",
+		Regexp:        regexp.MustCompile("^data/"),
+		MinSize:       50,
+		ModifiedAfter: time.Now().Add(-time.Hour),
+	}
+	var got []string
+	for ent, err := range fss3.Find("find", &q) {
+		if err != nil {
+			t.Fatalf("find error: %s", err)
+		}
+		got = append(got, ent.Path())
+	}
+	if len(got) != 1 || got[0] != "find/data/b.parquet" {
+		t.Errorf("find error, expect [find/data/b.parquet], but got %v", got)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	defer fss3.RemoveAll("usage")
+	files := map[string]int{
+		"usage/a":       1,
+		"usage/b/c":     10,
+		"usage/b/d/e":   100,
+		"usage/b/d/f/g": 1000,
+	}
+	for name, size := range files {
+		if err := fss3.WriteFile(name, make([]byte, size), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	u, err := fss3.Usage("usage", 2)
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Size != 1111 || u.Count != 4 {
+		t.Errorf("usage error, expect 1111 bytes in 4 files, but got %d bytes in %d files", u.Size, u.Count)
+	}
+	if len(u.Children) != 2 || u.Children[1].Path != "usage/b" || u.Children[1].Size != 1110 {
+		t.Fatalf("usage error, unexpected children %v", u.Children)
+	}
+	b := u.Children[1]
+	if len(b.Children) != 2 || b.Children[1].Path != "usage/b/d" || b.Children[1].Size != 1100 {
+		t.Fatalf("usage error, unexpected children %v", b.Children)
+	}
+	if len(b.Children[1].Children) != 0 {
+		t.Errorf("usage error, expect no children beyond depth 2")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	c := cfg
+	c.Checksum = ChecksumSHA256
+	if _, err := New(c); !errors.Is(err, ErrUnsupportedChecksum) {
+		t.Errorf("new error, expect unsupported checksum, but got %v", err)
+	}
+	c.Checksum = ChecksumMD5
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.Remove("checksum")
+	data := []byte("hello world")
+	if err := s3.WriteFile("checksum", data, 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if _, err := s3.ReadFile("checksum"); err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	sum, err := s3.Hash("checksum", ChecksumSHA256)
+	if err != nil {
+		t.Fatalf("hash error: %s", err)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256(data)); sum != want {
+		t.Errorf("hash error, expect %s, but got %s", want, sum)
+	}
+
+	stored, err := s3.Hash("checksum", ChecksumMD5)
+	if err != nil {
+		t.Fatalf("hash error: %s", err)
+	}
+	opts := putObjectOptions{UserMetadata: map[string]string{"checksum": "md5:" + stored}}
+	if _, err := s3.putObject("checksum", strings.NewReader("corrupted"), 9, &opts); err != nil {
+		t.Fatalf("put object error: %s", err)
+	}
+	_, err = s3.ReadFile("checksum")
+	var mismatch ErrChecksumMismatch
+	if !errors.As(err, &mismatch) {
+		t.Errorf("read file error, expect checksum mismatch, but got %v", err)
+	}
+	defer s3.RemoveAll("checksumdir")
+	if _, err := s3.putObject(fss3.nameToKey("checksumdir/checksum"), strings.NewReader("corrupted"), 9, &opts); err != nil {
+		t.Fatalf("put object error: %s", err)
+	}
+	err = s3.CopyToDir("checksumdir", t.TempDir())
+	if !errors.As(err, &mismatch) {
+		t.Errorf("copy to dir error, expect checksum mismatch, but got %v", err)
+	}
+}
+
+func TestConditionalWrite(t *testing.T) {
+	defer fss3.Remove("conditional")
+	if err := fss3.WriteFileWith("conditional", []byte("v1"), 0644, &WriteOptions{IfNoneMatch: "*"}); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	info, err := fss3.Stat("conditional")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	etag := info.(*FileInfo).ETag()
+	if err := fss3.WriteFileWith("conditional", []byte("v2"), 0644, &WriteOptions{IfMatch: etag}); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	var precondition ErrPreconditionFailed
+	err = fss3.WriteFileWith("conditional", []byte("v3"), 0644, &WriteOptions{IfMatch: etag})
+	if !errors.As(err, &precondition) {
+		t.Errorf("write file error, expect precondition failed, but got %v", err)
+	}
+	err = fss3.WriteFileWith("conditional", []byte("v3"), 0644, &WriteOptions{IfNoneMatch: "*"})
+	if !errors.As(err, &precondition) {
+		t.Errorf("write file error, expect precondition failed, but got %v", err)
+	}
+	if err := fss3.AtomicWriteFile("conditional", []byte("v4"), 0644, nil); err != nil {
+		t.Fatalf("atomic write file error: %s", err)
+	}
+	data, err := fss3.ReadFile("conditional")
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(data) != "v4" {
+		t.Errorf("atomic write file error, expect v4, but got %s", data)
+	}
+	err = fss3.AtomicWriteFile("conditional", []byte("v5"), 0644, &WriteOptions{IfNoneMatch: "*"})
+	if !errors.As(err, &precondition) {
+		t.Errorf("atomic write file error, expect precondition failed, but got %v", err)
+	}
+	ents, err := fss3.ReadDir(".")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	for _, e := range ents {
+		if strings.HasSuffix(e.Name(), ".conditional") {
+			t.Errorf("atomic write file error, temporary object %s listed", e.Name())
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	if _, err := fss3.TryLock("lock", 0); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("try lock error, expect invalid ttl, but got %v", err)
+	}
+	lease, err := fss3.TryLock("lock", time.Second)
+	if err != nil {
+		t.Fatalf("try lock error: %s", err)
+	}
+	var locked ErrLocked
+	if _, err := fss3.TryLock("lock", time.Second); !errors.As(err, &locked) {
+		t.Errorf("try lock error, expect locked, but got %v", err)
+	}
+	// The lease is renewed past its TTL.
+	time.Sleep(2 * time.Second)
+	if _, err := fss3.TryLock("lock", time.Second); !errors.As(err, &locked) {
+		t.Errorf("try lock error, expect locked, but got %v", err)
+	}
+	if err := lease.Unlock(); err != nil {
+		t.Fatalf("unlock error: %s", err)
+	}
+	next, err := fss3.Lock("lock", time.Second)
+	if err != nil {
+		t.Fatalf("lock error: %s", err)
+	}
+	defer next.Unlock()
+	if next.Token() <= lease.Token() {
+		t.Errorf("lock error, expect token greater than %d, but got %d", lease.Token(), next.Token())
+	}
+	ents, err := fss3.ReadDir(".")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	for _, ent := range ents {
+		if ent.Name() == fss3.cfg.LockPrefix {
+			t.Errorf("read dir error, expect lock prefix to be hidden")
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	defer fss3.RemoveAllWith("versions", &RemoveOptions{AllVersions: true})
+	for _, data := range []string{"v1", "v2"} {
+		if err := fss3.WriteFile("versions/file", []byte(data), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	versions, err := fss3.ListVersions("versions/file")
+	if err != nil {
+		t.Fatalf("list versions error: %s", err)
+	}
+	if len(versions) < 2 {
+		t.Skip("bucket versioning is disabled")
+	}
+	old := versions[1]
+	f, err := fss3.OpenVersion("versions/file", old.ID)
+	if err != nil {
+		t.Fatalf("open version error: %s", err)
+	}
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "v1" {
+		t.Errorf("open version error, expect v1, but got %q: %v", data, err)
+	}
+	if err := fss3.RestoreVersion("versions/file", old.ID); err != nil {
+		t.Fatalf("restore version error: %s", err)
+	}
+	data, err = fss3.ReadFile("versions/file")
+	if err != nil || string(data) != "v1" {
+		t.Errorf("restore version error, expect v1, but got %q: %v", data, err)
+	}
+	info, err := fss3.Stat("versions/file")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if err := fss3.RemoveVersion("versions/file", info.(*FileInfo).VersionID()); err != nil {
+		t.Fatalf("remove version error: %s", err)
+	}
+	versions, err = fss3.ListVersions("versions/file")
+	if err != nil {
+		t.Fatalf("list versions error: %s", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("remove version error, expect 2 versions, but got %d", len(versions))
+	}
+
+	var removed, failed int
+	opts := RemoveOptions{AllVersions: true, Progress: func(r, f int) { removed, failed = r, f }}
+	if err := fss3.RemoveWith("versions/file", &opts); err != nil {
+		t.Fatalf("remove error: %s", err)
+	}
+	if removed != 2 || failed != 0 {
+		t.Errorf("remove error, expect progress with 2 removed, but got %d removed, %d failed", removed, failed)
+	}
+	if versions, _ := fss3.ListVersions("versions/file"); len(versions) != 0 {
+		t.Errorf("remove error, expect no versions left, but got %d", len(versions))
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	defer fss3.RemoveAllWith("asof", &RemoveOptions{AllVersions: true})
+	if err := fss3.WriteFile("asof/a", []byte("v1"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	time.Sleep(time.Second)
+	at := time.Now()
+	time.Sleep(time.Second)
+	if err := fss3.WriteFile("asof/a", []byte("v2"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := fss3.WriteFile("asof/b", []byte("new"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if versions, _ := fss3.ListVersions("asof/a"); len(versions) < 2 {
+		t.Skip("bucket versioning is disabled")
+	}
+	view := fss3.AsOf(at)
+	data, err := fs.ReadFile(view, "asof/a")
+	if err != nil || string(data) != "v1" {
+		t.Errorf("as of error, expect v1, but got %q: %v", data, err)
+	}
+	if _, err := fs.Stat(view, "asof/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("as of error, expect asof/b not to exist, but got %v", err)
+	}
+	var paths []string
+	err = fs.WalkDir(view, "asof", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk dir error: %s", err)
+	}
+	if strings.Join(paths, ",") != "asof,asof/a" {
+		t.Errorf("as of error, expect [asof asof/a], but got %v", paths)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	defer fss3.RemoveAll("snap")
+	if err := fss3.WriteFile("snap/a", []byte("a"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := fss3.WriteFile("snap/b", []byte("b"), 0600); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	// snap/d shares the contents of snap/a, with another mode.
+	if err := fss3.WriteFile("snap/d", []byte("a"), 0600); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	id, err := fss3.Snapshot("snap")
+	if err != nil {
+		t.Fatalf("snapshot error: %s", err)
+	}
+	defer fss3.DeleteSnapshot(id)
+	snapshots, err := fss3.ListSnapshots()
+	if err != nil {
+		t.Fatalf("list snapshots error: %s", err)
+	}
+	found := false
+	for _, s := range snapshots {
+		if s.ID == id {
+			found = s.Dir == "snap" && s.Files == 3 && s.Size == 3
+		}
+	}
+	if !found {
+		t.Errorf("list snapshots error, expect %s with 3 files, but got %v", id, snapshots)
+	}
+
+	if err := fss3.WriteFile("snap/a", []byte("changed"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := fss3.Remove("snap/b"); err != nil {
+		t.Fatalf("remove error: %s", err)
+	}
+	if err := fss3.WriteFile("snap/c", []byte("c"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := fss3.Chmod("snap/d", 0644); err != nil {
+		t.Fatalf("chmod error: %s", err)
+	}
+	if err := fss3.Restore(id, "snap"); err != nil {
+		t.Fatalf("restore error: %s", err)
+	}
+	if data, err := fss3.ReadFile("snap/a"); err != nil || string(data) != "a" {
+		t.Errorf("restore error, expect a, but got %q: %v", data, err)
+	}
+	if info, err := fss3.Stat("snap/b"); err != nil || info.Mode() != 0600 {
+		t.Errorf("restore error, expect snap/b with mode 0600, but got %v: %v", info, err)
+	}
+	for name, mode := range map[string]fs.FileMode{"snap/a": 0644, "snap/d": 0600} {
+		if info, err := fss3.Stat(name); err != nil || info.Mode() != mode {
+			t.Errorf("restore error, expect %s with mode %o, but got %v: %v", name, mode, info, err)
+		}
+	}
+	if _, err := fss3.Stat("snap/c"); err == nil {
+		t.Errorf("restore error, expect snap/c to be removed")
+	}
+
+	if err := fss3.DeleteSnapshot(id); err != nil {
+		t.Fatalf("delete snapshot error: %s", err)
+	}
+	if err := fss3.Restore(id, "snap"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("delete snapshot error, expect not exist, but got %v", err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	c := cfg
+	c.Trash = true
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.RemoveAllWith("trash", &RemoveOptions{Permanent: true})
+	// The path is stored in metadata, where non-ASCII characters and spaces
+	// have to be escaped.
+	if err := s3.WriteFile("trash/dïr é/a", []byte("a"), 0600); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := s3.RemoveAll("trash/dïr é"); err != nil {
+		t.Fatalf("remove all error: %s", err)
+	}
+	if _, err := s3.Stat("trash/dïr é/a"); err == nil {
+		t.Fatalf("trash error, expect trash/dïr é/a to be removed")
+	}
+	items, err := s3.ListTrash()
+	if err != nil {
+		t.Fatalf("list trash error: %s", err)
+	}
+	var item *TrashItem
+	for i := range items {
+		if items[i].Path == "trash/dïr é" {
+			item = &items[i]
+		}
+	}
+	if item == nil || item.Files == 0 || time.Since(item.Deleted) > time.Minute {
+		t.Fatalf("list trash error, expect trash/dïr é, but got %v", items)
+	}
+	if err := s3.RestoreTrash(item.ID); err != nil {
+		t.Fatalf("restore trash error: %s", err)
+	}
+	info, err := s3.Stat("trash/dïr é/a")
+	if err != nil || info.Mode() != 0600 {
+		t.Errorf("restore trash error, expect trash/dïr é/a with mode 0600, but got %v: %v", info, err)
+	}
+
+	if err := s3.RemoveWith("trash/dïr é/a", &RemoveOptions{Permanent: true}); err != nil {
+		t.Fatalf("remove error: %s", err)
+	}
+	if items, _ := s3.ListTrash(); len(items) != 0 && items[len(items)-1].Path == "trash/dïr é/a" {
+		t.Errorf("remove error, expect permanent remove to bypass the trash")
+	}
+	if err := s3.EmptyTrash(0); err != nil {
+		t.Fatalf("empty trash error: %s", err)
+	}
+	if items, _ := s3.ListTrash(); len(items) != 0 {
+		t.Errorf("empty trash error, expect no items, but got %v", items)
+	}
+}
+
+func TestRemoveAllWith(t *testing.T) {
+	for _, name := range []string{"removeall/a", "removeall/b/c", "removeall-file"} {
+		if err := fss3.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	var removed, failed int
+	opts := RemoveOptions{Progress: func(r, f int) { removed, failed = r, f }}
+	if err := fss3.RemoveAllWith("removeall", &opts); err != nil {
+		t.Fatalf("remove all error: %s", err)
+	}
+	if removed < 2 || failed != 0 {
+		t.Errorf("remove all error, expect progress with at least 2 removed, but got %d removed, %d failed", removed, failed)
+	}
+	if _, err := fss3.Stat("removeall/b/c"); err == nil {
+		t.Errorf("remove all error, expect removeall/b/c to be removed")
+	}
+	if _, err := fss3.Stat("removeall-file"); err != nil {
+		t.Errorf("remove all error, expect removeall-file to be kept: %s", err)
+	}
+	if err := fss3.RemoveAll("removeall-file"); err != nil {
+		t.Fatalf("remove all error: %s", err)
+	}
+	if _, err := fss3.Stat("removeall-file"); err == nil {
+		t.Errorf("remove all error, expect removeall-file to be removed")
+	}
+}
+
+func TestSyncToDirTraversal(t *testing.T) {
+	key := "traversal/a/../../escaped"
+	if _, err := fss3.putObject(key, strings.NewReader("x"), 1, nil); err != nil {
+		t.Skipf("bucket rejects keys with \"..\": %s", err)
+	}
+	defer fss3.removeObject(key, nil)
+	dir := t.TempDir()
+	_, err := SyncToDir(fss3, "traversal", filepath.Join(dir, "dst"), nil)
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("sync to dir error, expect invalid path, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); err == nil {
+		t.Errorf("sync to dir error, expect nothing written outside the destination")
+	}
+}
+
+func TestSyncDeleteExcluded(t *testing.T) {
+	defer fss3.RemoveAll("syncx")
+	for _, name := range []string{"syncx/logs/skip.log", "syncx/old"} {
+		if err := fss3.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	// No modification time, as with embed.FS.
+	src := fstest.MapFS{"keep": {Data: []byte("keep"), Mode: 0644}}
+	opts := &SyncOptions{Delete: true, Exclude: []string{"*.log"}}
+	if _, err := Sync(src, fss3, "syncx", opts); err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	if _, err := fss3.Stat("syncx/logs/skip.log"); err != nil {
+		t.Errorf("sync error, expect excluded file to be kept: %s", err)
+	}
+	if _, err := fss3.Stat("syncx/old"); err == nil {
+		t.Errorf("sync error, expect syncx/old to be deleted")
+	}
+	report, err := Sync(src, fss3, "syncx", opts)
+	if err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	if len(report.Copied) != 0 || len(report.Unchanged) != 1 {
+		t.Errorf("sync error, expect keep to be unchanged, but got %+v", report)
+	}
+}
+
+func TestSyncToDirDeleteExcluded(t *testing.T) {
+	defer fss3.RemoveAll("syncdx")
+	if err := fss3.WriteFile("syncdx/keep", []byte("keep"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	// A file written without its parent directory marker.
+	if _, err := fss3.putObject(fss3.nameToKey("syncdx/nomarker/file"), strings.NewReader("file"), 4, nil); err != nil {
+		t.Fatalf("put object error: %s", err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"logs/skip.log", "old/file"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := &SyncOptions{Delete: true, Exclude: []string{"*.log"}}
+	if _, err := SyncToDir(fss3, "syncdx", dir, opts); err != nil {
+		t.Fatalf("sync to dir error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs", "skip.log")); err != nil {
+		t.Errorf("sync to dir error, expect excluded file to be kept: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); err == nil {
+		t.Errorf("sync to dir error, expect old to be deleted")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "nomarker", "file")); err != nil || string(data) != "file" {
+		t.Errorf("sync to dir error, expect nomarker/file, but got %q: %v", data, err)
+	}
+}
+
+func TestCopyToDirModes(t *testing.T) {
+	defer fss3.RemoveAll("copymode")
+	if err := fss3.MkdirAll("copymode/ro", 0555); err != nil {
+		t.Fatalf("mkdir error: %s", err)
+	}
+	if err := fss3.WriteFile("copymode/ro/a", []byte("a"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	remote, err := fss3.Stat("copymode/ro")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	dir := t.TempDir()
+	t.Cleanup(func() { os.Chmod(filepath.Join(dir, "ro"), 0755) })
+	if err := fss3.CopyToDir("copymode", dir); err != nil {
+		t.Fatalf("copy to dir error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ro", "a")); err != nil {
+		t.Errorf("copy to dir error, expect ro/a: %s", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "ro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0555 {
+		t.Errorf("copy to dir error, expect ro with mode 0555, but got %o", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(remote.ModTime()) {
+		t.Errorf("copy to dir error, expect ro modified at %s, but got %s", remote.ModTime(), info.ModTime())
+	}
+}
+
+func TestWalkTraversal(t *testing.T) {
+	key := "walkt/a/../../b"
+	if _, err := fss3.putObject(key, strings.NewReader("x"), 1, nil); err != nil {
+		t.Skipf("bucket rejects keys with \"..\": %s", err)
+	}
+	defer fss3.removeObject(key, nil)
+	if err := fss3.WriteFile("walkt/c", []byte("c"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	defer fss3.RemoveAll("walkt")
+	done := make(chan []string)
+	go func() {
+		var names []string
+		for e, err := range fss3.Walk("walkt") {
+			if err != nil {
+				t.Errorf("walk error: %s", err)
+				break
+			}
+			names = append(names, e.Path())
+		}
+		done <- names
+	}()
+	select {
+	case names := <-done:
+		if len(names) != 1 || names[0] != "walkt/c" {
+			t.Errorf("walk error, expect only walkt/c, but got %v", names)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("walk error, expect the walk to end")
+	}
+}
+
+func TestBisyncTraversal(t *testing.T) {
+	key := "bisynct/../../escaped"
+	if _, err := fss3.putObject(key, strings.NewReader("x"), 1, nil); err != nil {
+		t.Skipf("bucket rejects keys with \"..\": %s", err)
+	}
+	defer fss3.removeObject(key, nil)
+	dir := t.TempDir()
+	local := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(local, 0755); err != nil {
+		t.Fatal(err)
+	}
+	Bisync(local, fss3, "bisynct", nil)
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.Name() == "escaped" && !strings.HasPrefix(p, local+string(filepath.Separator)) {
+			t.Errorf("bisync error, expect nothing written outside the local directory, but got %s", p)
+		}
+		return nil
+	})
+}
+
+func TestBisyncKeepBoth(t *testing.T) {
+	local := t.TempDir()
+	defer fss3.RemoveAll("bisynckb")
+	if err := fss3.WriteFile("bisynckb/f", []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &BisyncOptions{Conflict: ConflictKeepBoth}
+	if _, err := Bisync(local, fss3, "bisynckb", opts); err != nil {
+		t.Fatalf("bisync error: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(local, "f.conflict"), []byte("taken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(local, "f"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fss3.WriteFile("bisynckb/f", []byte("remote"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Bisync(local, fss3, "bisynckb", opts); err != nil {
+		t.Fatalf("bisync error: %s", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(local, "f.conflict")); string(b) != "taken" {
+		t.Errorf("bisync error, expect f.conflict to be kept, but got %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(local, "f.conflict.1")); string(b) != "local" {
+		t.Errorf("bisync error, expect local version in f.conflict.1, but got %q", b)
+	}
+}
+
+func TestCopyToDirTraversal(t *testing.T) {
+	key := "copytraversal/a/../../escaped"
+	if _, err := fss3.putObject(key, strings.NewReader("x"), 1, nil); err != nil {
+		t.Skipf("bucket rejects keys with \"..\": %s", err)
+	}
+	defer fss3.removeObject(key, nil)
+	dir := t.TempDir()
+	err := fss3.CopyToDir("copytraversal", filepath.Join(dir, "dst"))
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("copy to dir error, expect invalid path, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); err == nil {
+		t.Errorf("copy to dir error, expect nothing written outside the destination")
+	}
+}
+
+func TestRootDirFileName(t *testing.T) {
+	name := fss3.cfg.DirFileName
+	if name == "." {
+		t.Skip("DirFileName is not a valid file name")
+	}
+	if err := fss3.WriteFile(name, []byte("file"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	defer fss3.Remove(name)
+	info, err := fss3.Stat(name)
+	if err != nil || info.IsDir() || info.Name() != name {
+		t.Fatalf("stat error, expect file %q, but got %v: %v", name, info, err)
+	}
+	if data, err := fss3.ReadFile(name); err != nil || string(data) != "file" {
+		t.Errorf("read file error, expect file, but got %q: %v", data, err)
+	}
+	if info, err := fss3.Stat("."); err != nil || !info.IsDir() {
+		t.Errorf("stat error, expect the root to stay a directory: %v", err)
+	}
+}
+
+func TestFindPrefix(t *testing.T) {
+	tests := map[string]string{
+		`^data/2024/.*\.parquet$`: "data/2024",
+		`^data/x`:                 "data",
+		`^file`:                   "",
+		`data/.*`:                 "",
+		`(?i)^data/`:              "",
+	}
+	for expr, want := range tests {
+		q := Query{Regexp: regexp.MustCompile(expr)}
+		if got := q.prefix(); got != want {
+			t.Errorf("find prefix error, expect %q for %s, but got %q", want, expr, got)
+		}
+	}
+}
+
+func TestUsageMarkerSlash(t *testing.T) {
+	c := cfg
+	c.DirMarker = MarkerSlash
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.RemoveAll("usageslash")
+	if err := s3.Mkdir("usageslash", 0755); err != nil {
+		t.Fatalf("mkdir error: %s", err)
+	}
+	if err := s3.WriteFile("usageslash/sub/a", []byte("abc"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	u, err := s3.Usage("usageslash", 1)
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Size != 3 || u.Count != 1 {
+		t.Errorf("usage error, expect 3 bytes in 1 file, but got %+v", u.UsageTotal)
+	}
+	if len(u.Children) != 1 || u.Children[0].Path != "usageslash/sub" {
+		t.Errorf("usage error, expect only usageslash/sub as child, but got %v", u.Children)
+	}
+}
+
+// faultTransport fails the listings of the key listFail and the deletions of
+// the keys containing "fail".
+type faultTransport struct {
+	http.RoundTripper
+	listFail string
+}
+
+func (t faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	respond := func(status int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/xml"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}
+	}
+	if req.Method == http.MethodGet && query.Has("prefix") && query.Get("prefix") == t.listFail {
+		return respond(http.StatusForbidden, "<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>"), nil
+	}
+	if req.Method != http.MethodPost || !query.Has("delete") {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	var del struct {
+		Objects []struct{ Key string } `xml:"Object"`
+	}
+	if err := xml.NewDecoder(req.Body).Decode(&del); err != nil {
+		return nil, err
+	}
+	var body strings.Builder
+	body.WriteString("<DeleteResult>")
+	for _, obj := range del.Objects {
+		if strings.Contains(obj.Key, "fail") {
+			fmt.Fprintf(&body, "<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>", obj.Key)
+		} else {
+			fmt.Fprintf(&body, "<Deleted><Key>%s</Key></Deleted>", obj.Key)
+		}
+	}
+	body.WriteString("</DeleteResult>")
+	return respond(http.StatusOK, body.String()), nil
+}
+
+func TestRemoveAllWithErrors(t *testing.T) {
+	defer fss3.RemoveAll("removeerr")
+	names := []string{"removeerr/fail-a", "removeerr/fail-b", "removeerr/ok"}
+	for _, name := range names {
+		if err := fss3.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	s3, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, err := minio.DefaultTransport(cfg.UseSSL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3.client, err = minio.New(cfg.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:    cfg.UseSSL,
+		Region:    cfg.Region,
+		Transport: faultTransport{RoundTripper: transport, listFail: fss3.nameToKey("removeerr")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var removed, failed int
+	opts := RemoveOptions{Progress: func(r, f int) { removed, failed = r, f }}
+	err = s3.RemoveAllWith("removeerr", &opts)
+	if err == nil {
+		t.Fatalf("remove all error, expect errors")
+	}
+	// The failed listing of the key removeerr is reported along with the
+	// failed deletions, and the other objects are still removed.
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != 3 {
+		t.Errorf("remove all error, expect the listing error and 2 deletion errors, but got %v", err)
+	}
+	paths := make(map[string]bool)
+	for _, e := range errs {
+		var pathErr *fs.PathError
+		if errors.As(e, &pathErr) {
+			paths[pathErr.Path] = true
+		}
+	}
+	for _, name := range names[:2] {
+		if !paths[name] {
+			t.Errorf("remove all error, expect %s in %v", name, err)
+		}
+	}
+	if failed != 2 || removed < 1 {
+		t.Errorf("remove all error, expect progress with 2 failed, but got %d removed, %d failed", removed, failed)
+	}
+}
+
+// identityEncoder stores path elements as is.
+type identityEncoder struct{}
+
+func (identityEncoder) Encode(elem string) string { return elem }
+func (identityEncoder) Decode(elem string) string { return elem }
+
+func TestKeyEncoderPerInstance(t *testing.T) {
+	c := cfg
+	c.KeyEncoder = identityEncoder{}
+	c.DirFileName = ".dir"
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := s3.nameToKey("a%b"); key != "a%b" {
+		t.Errorf("name to key error, expect a%%b, but got %s", key)
+	}
+	if key := other.nameToKey("a%b"); key != "a%25b" {
+		t.Errorf("name to key error, expect a%%25b, but got %s", key)
+	}
+	if key := s3.nameToKey(rootName); key != ".dir" {
+		t.Errorf("name to key error, expect .dir, but got %s", key)
+	}
+	if name := other.keyToName(".dir"); other.cfg.DirFileName != ".dir" && name != ".dir" {
+		t.Errorf("key to name error, expect .dir, but got %s", name)
+	}
+}
+
+func TestListHiddenPage(t *testing.T) {
+	c := cfg
+	c.TempPrefix = "listhide/.hidden"
+	s3, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s3.RemoveAll("listhide")
+	defer s3.RemoveAll("listhide/.hidden")
+	// A full page of hidden keys, sorting before listhide/a.
+	keys := make([]int, 1000)
+	for i := range keys {
+		keys[i] = i
+	}
+	err = forEach(keys, 16, func(i int) error {
+		_, err := s3.putObject(s3.nameToKey(fmt.Sprintf("listhide/.hidden/%04d", i)), strings.NewReader(""), 0, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("put object error: %s", err)
+	}
+	if err := s3.WriteFile("listhide/a", []byte("a"), 0644); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+
+	var names []string
+	opts := ListOptions{Recursive: true}
+	for {
+		ents, token, err := s3.List("listhide", &opts)
+		if err != nil {
+			t.Fatalf("list error: %s", err)
+		}
+		for _, e := range ents {
+			names = append(names, e.Path())
+		}
+		if token == "" {
+			break
+		}
+		opts.Token = token
+	}
+	if len(names) != 1 || names[0] != "listhide/a" {
+		t.Errorf("list error, expect listhide/a, but got %v", names)
+	}
+
+	names = nil
+	for e, err := range s3.Find("listhide", nil) {
+		if err != nil {
+			t.Fatalf("find error: %s", err)
+		}
+		names = append(names, e.Path())
+	}
+	if len(names) != 1 || names[0] != "listhide/a" {
+		t.Errorf("find error, expect listhide/a, but got %v", names)
+	}
+}
+
 func contains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
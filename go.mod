@@ -2,7 +2,10 @@ module github.com/aymanbagabas/fss3
 
 go 1.25.0
 
-require github.com/minio/minio-go/v7 v7.0.12
+require (
+	github.com/minio/minio-go/v7 v7.0.12
+	golang.org/x/term v0.43.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.0 // indirect
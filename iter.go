@@ -0,0 +1,195 @@
+package fss3
+
+import (
+	"context"
+	"io/fs"
+	"iter"
+	"path"
+	"strings"
+)
+
+// Entry is a fs.DirEntry read from a listing. The metadata missing from the
+// listing is fetched when Info is called.
+type Entry struct {
+	fss3 *FSS3
+	name string
+	info *FileInfo
+	dir  bool
+}
+
+// Name returns the base name of the entry.
+func (e *Entry) Name() string {
+	return path.Base(e.name)
+}
+
+// Path returns the path of the entry in the bucket.
+func (e *Entry) Path() string {
+	return e.name
+}
+
+// IsDir reports whether the entry is a directory.
+func (e *Entry) IsDir() bool {
+	return e.dir
+}
+
+// Type returns the type bits for the entry.
+func (e *Entry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+// Info returns the FileInfo describing the entry.
+func (e *Entry) Info() (fs.FileInfo, error) {
+	// Directories without a marker have no metadata to fetch.
+	if err := e.fss3.fillMetadata(e.info); err != nil && !(e.dir && isNotExist(err)) {
+		return nil, minioErrToPathErr(err)
+	}
+	if e.dir {
+		info := e.fss3.dirInfo(e.name, *e.info.info)
+		e.info.info = &info
+	}
+	return e.info, nil
+}
+
+// newEntry returns the Entry of the object described by info.
+func (fss3 *FSS3) newEntry(name string, info objectInfo, dir bool) *Entry {
+	return &Entry{fss3: fss3, name: name, info: fss3.newFileInfo(info), dir: dir}
+}
+
+// implicitEntry returns the Entry of the directory name, whose marker wasn't
+// listed (yet).
+func (fss3 *FSS3) implicitEntry(name string) *Entry {
+	key := fss3.dirKey(name)
+	if key == "" {
+		key = fss3.dirPrefix(name)
+	}
+	return fss3.newEntry(name, objectInfo{Key: key}, true)
+}
+
+// Entries returns an iterator over the entries of the directory dir, in
+// lexical order of their keys. Entries are yielded as the pages of the
+// listing arrive and the listing stops when the loop breaks. Errors are
+// yielded in the second value.
+func (fss3 *FSS3) Entries(dir string) iter.Seq2[*Entry, error] {
+	return func(yield func(*Entry, error) bool) {
+		dir := sanitizeName(dir)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := listObjectsOptions{
+			Prefix:       fss3.dirPrefix(dir),
+			WithMetadata: true,
+		}
+		empty := true
+		for obj := range fss3.listObjectsContext(ctx, &opts) {
+			if obj.Err != nil {
+				yield(nil, minioErrToPathErr(obj.Err))
+				return
+			}
+			empty = false
+			if name, ok := fss3.markerDir(obj.Key); ok && name == dir {
+				continue
+			}
+			name := fss3.keyToName(obj.Key)
+			if !yield(fss3.newEntry(name, obj, strings.HasSuffix(obj.Key, "/")), nil) {
+				return
+			}
+		}
+		if empty {
+			if _, err := fss3.statDir(dir); err != nil {
+				yield(nil, minioErrToPathErr(err))
+			}
+		}
+	}
+}
+
+// Walk returns an iterator over the files and directories under root,
+// excluding root, read with a single recursive listing. Entries are yielded
+// in lexical order of their keys, with directories before their contents,
+// as the pages of the listing arrive. The listing stops when the loop
+// breaks. Errors are yielded in the second value. Keys that aren't valid
+// paths under root, such as keys with ".." elements, are skipped.
+func (fss3 *FSS3) Walk(root string) iter.Seq2[*Entry, error] {
+	return func(yield func(*Entry, error) bool) {
+		root := sanitizeName(root)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := listObjectsOptions{
+			Prefix:       fss3.dirPrefix(root),
+			Recursive:    true,
+			WithMetadata: true,
+		}
+		// open holds the yielded directories containing the current key,
+		// outermost first.
+		var open []string
+		// leave closes the open directories not containing dir.
+		leave := func(dir string) {
+			for len(open) > 0 && dir != open[len(open)-1] && !strings.HasPrefix(dir, open[len(open)-1]+"/") {
+				open = open[:len(open)-1]
+			}
+		}
+		// enter yields the directories from the last open one down to dir.
+		enter := func(dir string) bool {
+			leave(dir)
+			var missing []string
+			for d := dir; d != root && (len(open) == 0 || d != open[len(open)-1]); d = sanitizeName(path.Dir(d)) {
+				missing = append(missing, d)
+			}
+			for i := len(missing) - 1; i >= 0; i-- {
+				open = append(open, missing[i])
+				if !yield(fss3.implicitEntry(missing[i]), nil) {
+					return false
+				}
+			}
+			return true
+		}
+
+		// under reports whether name is a valid path inside root, which enter
+		// can walk up from.
+		under := func(name string) bool {
+			return fs.ValidPath(name) && (root == rootName || name == root || strings.HasPrefix(name, root+"/"))
+		}
+
+		empty := true
+		for obj := range fss3.listObjectsContext(ctx, &opts) {
+			if obj.Err != nil {
+				yield(nil, minioErrToPathErr(obj.Err))
+				return
+			}
+			empty = false
+			if name, ok := fss3.markerDir(obj.Key); ok {
+				if !under(name) {
+					continue
+				}
+				leave(name)
+				if name == root || len(open) > 0 && open[len(open)-1] == name {
+					continue
+				}
+				if !enter(sanitizeName(path.Dir(name))) {
+					return
+				}
+				open = append(open, name)
+				if !yield(fss3.newEntry(name, obj, true), nil) {
+					return
+				}
+				continue
+			}
+			name := fss3.keyToName(obj.Key)
+			if !under(name) {
+				continue
+			}
+			if !enter(sanitizeName(path.Dir(name))) {
+				return
+			}
+			if !yield(fss3.newEntry(name, obj, false), nil) {
+				return
+			}
+		}
+		if empty {
+			if _, err := fss3.statDir(root); err != nil {
+				yield(nil, minioErrToPathErr(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package fss3
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// KeyEncoder converts path elements to object key elements and back.
+// Decode(Encode(elem)) must return elem for every valid path element, and
+// encoded elements must not contain slashes.
+type KeyEncoder interface {
+	Encode(elem string) string
+	Decode(elem string) string
+}
+
+// PercentEncoder is the default KeyEncoder. It percent-encodes '%',
+// backslashes, control characters and trailing spaces, and the first byte of
+// elements equal to one of the Reserved names. Other names are stored as is.
+type PercentEncoder struct {
+	// Reserved are the key elements with a special meaning, such as
+	// Config.DirFileName.
+	Reserved []string
+}
+
+// Encode encodes a path element.
+func (e PercentEncoder) Encode(elem string) string {
+	trailing := len(elem) - len(strings.TrimRight(elem, " "))
+	var b strings.Builder
+	for i := 0; i < len(elem); i++ {
+		c := elem[i]
+		if c == '%' || c == '\\' || c < 0x20 || c == 0x7f || i >= len(elem)-trailing {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	s := b.String()
+	for _, r := range e.Reserved {
+		if s != "" && s == r {
+			return fmt.Sprintf("%%%02X", s[0]) + s[1:]
+		}
+	}
+	return s
+}
+
+// Decode decodes a key element. Percent signs not followed by two hex digits
+// are kept as is.
+func (e PercentEncoder) Decode(elem string) string {
+	if !strings.Contains(elem, "%") {
+		return elem
+	}
+	var b strings.Builder
+	for i := 0; i < len(elem); i++ {
+		if elem[i] == '%' && i+2 < len(elem) {
+			if c, err := strconv.ParseUint(elem[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(elem[i])
+	}
+	return b.String()
+}
+
+// rootName is the sanitized name of the root directory. Unlike DirFileName,
+// it can't clash with the name of a file.
+const rootName = "."
+
+// nameToKey returns the object key of a sanitized path name. Elements equal
+// to DirFileName are encoded, so a file with that name doesn't clash with
+// the root.
+func (fss3 *FSS3) nameToKey(name string) string {
+	if name == rootName {
+		return fss3.cfg.DirFileName
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		elems[i] = fss3.cfg.KeyEncoder.Encode(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+// keyToName returns the path name of an object key.
+func (fss3 *FSS3) keyToName(key string) string {
+	key = strings.Trim(key, "/")
+	if key == "" || key == "." || key == fss3.cfg.DirFileName {
+		return rootName
+	}
+	elems := strings.Split(key, "/")
+	for i, elem := range elems {
+		elems[i] = fss3.cfg.KeyEncoder.Decode(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+// keyBaseName returns the base name of the file or directory of a key.
+// Directory marker keys return the name of their directory.
+func (fss3 *FSS3) keyBaseName(key string) string {
+	key = strings.Trim(key, "/")
+	if key == "" || key == fss3.cfg.DirFileName {
+		return rootName
+	}
+	base := path.Base(key)
+	if base == fss3.cfg.DirFileName {
+		base = path.Base(path.Dir(key))
+	}
+	return fss3.cfg.KeyEncoder.Decode(base)
+}
@@ -0,0 +1,153 @@
+package fss3
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Layout selects the metadata and directory conventions used in the bucket,
+// so that trees can be shared with other tools mounting the same bucket.
+type Layout int
+
+const (
+	// LayoutFSS3 stores the mode as an octal fs.FileMode under "mode" and the
+	// modification time as fractional Unix seconds under "mtime".
+	// Directories are stored according to Config.DirMarker.
+	LayoutFSS3 Layout = iota
+	// LayoutS3FS is compatible with s3fs-fuse. Directories are "dir/" objects
+	// and the mode (a decimal st_mode), uid, gid and modification time (Unix
+	// seconds) are stored under "mode", "uid", "gid" and "mtime".
+	LayoutS3FS
+	// LayoutRclone is compatible with rclone. Directories are derived from key
+	// prefixes, the modification time is stored as fractional Unix seconds
+	// under "mtime" and the mode, an octal st_mode, under "mode".
+	LayoutRclone
+	// LayoutGoofys is compatible with goofys. Directories are "dir/" objects
+	// and no metadata is stored.
+	LayoutGoofys
+)
+
+// Unix file type bits of st_mode.
+const (
+	unixModeType    = 0170000
+	unixModeDir     = 0040000
+	unixModeRegular = 0100000
+	unixModeSymlink = 0120000
+)
+
+// dirMarker returns the DirMarker used by the layout.
+func (l Layout) dirMarker(def DirMarker) DirMarker {
+	switch l {
+	case LayoutS3FS, LayoutGoofys:
+		return MarkerSlash
+	case LayoutRclone:
+		return MarkerNone
+	}
+	return def
+}
+
+// toUnixMode converts mode to a Unix st_mode.
+func toUnixMode(mode fs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= 01000
+	}
+	switch {
+	case mode.IsDir():
+		m |= unixModeDir
+	case mode&fs.ModeSymlink != 0:
+		m |= unixModeSymlink
+	default:
+		m |= unixModeRegular
+	}
+	return m
+}
+
+// fromUnixMode converts a Unix st_mode to a fs.FileMode.
+func fromUnixMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0777)
+	if m&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	switch m & unixModeType {
+	case unixModeDir:
+		mode |= fs.ModeDir
+	case unixModeSymlink:
+		mode |= fs.ModeSymlink
+	}
+	return mode
+}
+
+// objectMetadata returns the user metadata describing an object with the
+// given mode and modification time. A zero modTime isn't stored.
+func (fss3 *FSS3) objectMetadata(mode fs.FileMode, modTime time.Time) map[string]string {
+	meta := make(map[string]string)
+	switch fss3.cfg.Layout {
+	case LayoutGoofys:
+	case LayoutS3FS:
+		meta["mode"] = strconv.FormatUint(uint64(toUnixMode(mode)), 10)
+		if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 && gid >= 0 {
+			meta["uid"] = strconv.Itoa(uid)
+			meta["gid"] = strconv.Itoa(gid)
+		}
+		if modTime.IsZero() {
+			modTime = time.Now()
+		}
+		meta["mtime"] = strconv.FormatInt(modTime.Unix(), 10)
+	case LayoutRclone:
+		meta["mode"] = strconv.FormatUint(uint64(toUnixMode(mode)), 8)
+		if !modTime.IsZero() {
+			meta["mtime"] = formatModTime(modTime)
+		}
+	default:
+		meta["mode"] = fmt.Sprintf("%o", mode)
+		if !modTime.IsZero() {
+			meta["mtime"] = formatModTime(modTime)
+		}
+	}
+	return meta
+}
+
+// normalize converts the metadata of info from the layout's format to the
+// format of LayoutFSS3, which is what FileInfo reads. If complete is true,
+// info holds all the object metadata and missing modes are set to
+// Config.FileMode.
+func (fss3 *FSS3) normalize(info objectInfo, complete bool) objectInfo {
+	if fss3.cfg.Layout == LayoutFSS3 || info.Err != nil {
+		return info
+	}
+	meta := make(minio.StringMap, len(info.UserMetadata)+1)
+	for k, v := range info.UserMetadata {
+		meta[k] = v
+	}
+	base := 10
+	if fss3.cfg.Layout == LayoutRclone {
+		base = 8
+	}
+	if m, err := strconv.ParseUint(meta["Mode"], base, 32); err == nil && fss3.cfg.Layout != LayoutGoofys {
+		meta["Mode"] = fmt.Sprintf("%o", fromUnixMode(uint32(m)))
+	} else if _, isDir := fss3.markerDir(info.Key); complete && !isDir {
+		meta["Mode"] = fmt.Sprintf("%o", fss3.cfg.FileMode)
+	} else {
+		delete(meta, "Mode")
+	}
+	info.UserMetadata = meta
+	return info
+}
@@ -0,0 +1,107 @@
+package fss3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// ListOptions configures List.
+type ListOptions struct {
+	// PageSize is the maximum number of entries returned. Defaults to 1000.
+	PageSize int
+	// Token continues the listing returned by a previous call with the same
+	// directory and Recursive value.
+	Token string
+	// Recursive lists everything under the directory instead of only its
+	// entries. Directories are then only returned if they have a marker.
+	Recursive bool
+	// StartAfter lists the entries whose paths sort after this path. It's
+	// ignored when Token is set.
+	StartAfter string
+}
+
+// listToken is the state of a listing serialized in the tokens returned by
+// List.
+type listToken struct {
+	Dir       string `json:"d"`
+	Recursive bool   `json:"r,omitempty"`
+	Marker    string `json:"m"`
+}
+
+func (t listToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListToken(s string) (listToken, bool) {
+	var t listToken
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, false
+	}
+	return t, json.Unmarshal(data, &t) == nil
+}
+
+// List returns a page of the entries of the directory dir, in lexical order
+// of their keys, and the token continuing the listing. The token is empty
+// once the listing is complete. Tokens are opaque strings that can be stored
+// and passed to List later, such as in the links of a web page.
+func (fss3 *FSS3) List(dir string, opts *ListOptions) ([]*Entry, string, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	dir = sanitizeName(dir)
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+	prefix := fss3.dirPrefix(dir)
+	marker := ""
+	if opts.Token != "" {
+		t, ok := decodeListToken(opts.Token)
+		if !ok || t.Dir != dir || t.Recursive != opts.Recursive || !strings.HasPrefix(t.Marker, prefix) {
+			return nil, "", &fs.PathError{Op: "list", Path: dir, Err: fs.ErrInvalid}
+		}
+		marker = t.Marker
+	} else if opts.StartAfter != "" {
+		marker = fss3.nameToKey(sanitizeName(opts.StartAfter))
+	}
+	delimiter := "/"
+	if opts.Recursive {
+		delimiter = ""
+	}
+
+	result, last, err := fss3.listPage(prefix, marker, delimiter, pageSize)
+	if err != nil {
+		return nil, "", minioErrToPathErr(err)
+	}
+	objs := result.Contents
+	for _, p := range result.CommonPrefixes {
+		objs = append(objs, objectInfo{Key: p.Prefix})
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].Key < objs[j].Key
+	})
+
+	ents := make([]*Entry, 0, len(objs))
+	for _, obj := range objs {
+		name, isDir := fss3.markerDir(obj.Key)
+		if !isDir {
+			name = fss3.keyToName(obj.Key)
+		}
+		if isDir && name == dir {
+			continue
+		}
+		ents = append(ents, fss3.newEntry(name, obj, isDir))
+	}
+	// Pages can be empty when they only hold hidden objects, so the listing
+	// goes on while it's truncated.
+	if !result.IsTruncated || last == "" {
+		return ents, "", nil
+	}
+	token := listToken{Dir: dir, Recursive: opts.Recursive, Marker: last}
+	return ents, token.encode(), nil
+}
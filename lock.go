@@ -0,0 +1,257 @@
+package fss3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// MinLockTTL is the shortest TTL of a lock lease. Shorter leases couldn't be
+// renewed in time.
+const MinLockTTL = time.Second
+
+// lockState is the content of a lock object.
+type lockState struct {
+	Owner   string    `json:"owner,omitempty"`
+	Token   int64     `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// held reports whether the lock is held by a lease that hasn't expired.
+func (s *lockState) held() bool {
+	return s.Owner != "" && time.Now().Before(s.Expires)
+}
+
+// Lease is a lock acquired with Lock or TryLock. It is renewed in the
+// background until Unlock is called or the lock is lost.
+type Lease struct {
+	fss3  *FSS3
+	name  string
+	key   string
+	owner string
+	token int64
+	ttl   time.Duration
+
+	mu       sync.Mutex
+	etag     string
+	err      error
+	released bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Token returns the fencing token of the lease. Tokens increase every time
+// the lock is acquired, so the resources protected by the lock can reject
+// the writes of holders whose lease was taken over.
+func (l *Lease) Token() int64 {
+	return l.token
+}
+
+// Done returns a channel closed when the lease ends, because it was
+// released or lost.
+func (l *Lease) Done() <-chan struct{} {
+	return l.done
+}
+
+// Err returns ErrLeaseLost if the lease was lost, such as when it couldn't
+// be renewed before expiring and was taken over.
+func (l *Lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// lockKey returns the key of the lock object of name.
+func (fss3 *FSS3) lockKey(name string) string {
+	return fss3.nameToKey(fss3.joinName(sanitizeName(fss3.cfg.LockPrefix), sanitizeName(name)))
+}
+
+// readLock returns the state of the lock object at key and its ETag. A
+// missing lock object has an empty ETag.
+func (fss3 *FSS3) readLock(key string) (lockState, string, error) {
+	var state lockState
+	obj, err := fss3.getObject(key, nil)
+	if err != nil {
+		return state, "", err
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil {
+		if isNotExist(err) {
+			return state, "", nil
+		}
+		return state, "", err
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return state, "", err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, "", err
+	}
+	return state, info.ETag, nil
+}
+
+// writeLock writes state to the lock object at key if its ETag is still
+// etag, or if it doesn't exist when etag is empty. It returns the new ETag.
+func (fss3 *FSS3) writeLock(ctx context.Context, key string, state lockState, etag string) (string, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	wopts := WriteOptions{IfMatch: etag}
+	if etag == "" {
+		wopts = WriteOptions{IfNoneMatch: "*"}
+	}
+	opts := putObjectOptions{ContentType: "application/json"}
+	info, err := fss3.putObjectContext(wopts.context(ctx), key, bytes.NewReader(data), int64(len(data)), &opts)
+	if err != nil {
+		return "", writeErr(fss3.keyToName(key), err)
+	}
+	return info.ETag, nil
+}
+
+// TryLock acquires the advisory lock name for ttl, without waiting. The
+// lock is stored as an object under Config.LockPrefix, created or taken
+// over with a conditional PUT, so only one lease can hold it at a time.
+// Locks whose lease expired are taken over. It returns ErrLocked if the
+// lock is held, and fs.ErrInvalid if ttl is shorter than MinLockTTL.
+//
+// Mutual exclusion relies on the server honouring the If-None-Match and
+// If-Match headers of PUT requests. On servers ignoring them, concurrent
+// callers can all acquire the lock.
+func (fss3 *FSS3) TryLock(name string, ttl time.Duration) (*Lease, error) {
+	return fss3.tryLock(context.Background(), name, ttl)
+}
+
+func (fss3 *FSS3) tryLock(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	name = sanitizeName(name)
+	if ttl < MinLockTTL {
+		return nil, &fs.PathError{Op: "lock", Path: name, Err: fs.ErrInvalid}
+	}
+	key := fss3.lockKey(name)
+	state, etag, err := fss3.readLock(key)
+	if err != nil {
+		return nil, minioErrToPathErr(err)
+	}
+	if state.held() {
+		return nil, ErrLocked{name: name}
+	}
+
+	var owner [16]byte
+	if _, err := rand.Read(owner[:]); err != nil {
+		return nil, err
+	}
+	next := lockState{
+		Owner:   hex.EncodeToString(owner[:]),
+		Token:   state.Token + 1,
+		Expires: time.Now().Add(ttl),
+	}
+	etag, err = fss3.writeLock(ctx, key, next, etag)
+	var precondition ErrPreconditionFailed
+	if errors.As(err, &precondition) {
+		return nil, ErrLocked{name: name}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lease{
+		fss3:  fss3,
+		name:  name,
+		key:   key,
+		owner: next.Owner,
+		token: next.Token,
+		ttl:   ttl,
+		etag:  etag,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.renew()
+	return l, nil
+}
+
+// Lock acquires the advisory lock name for ttl like TryLock, waiting until
+// the lock is released or its lease expires.
+func (fss3 *FSS3) Lock(name string, ttl time.Duration) (*Lease, error) {
+	return fss3.LockContext(context.Background(), name, ttl)
+}
+
+// LockContext is like Lock but stops waiting when ctx is done.
+func (fss3 *FSS3) LockContext(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	wait := ttl / 4
+	if wait > time.Second {
+		wait = time.Second
+	}
+	for {
+		l, err := fss3.tryLock(ctx, name, ttl)
+		var locked ErrLocked
+		if !errors.As(err, &locked) {
+			return l, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// renew extends the lease every third of its TTL until it's released or
+// lost.
+func (l *Lease) renew() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	expires := time.Now().Add(l.ttl)
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		state := lockState{Owner: l.owner, Token: l.token, Expires: time.Now().Add(l.ttl)}
+		l.mu.Lock()
+		etag, err := l.fss3.writeLock(context.Background(), l.key, state, l.etag)
+		var precondition ErrPreconditionFailed
+		switch {
+		case err == nil:
+			l.etag = etag
+			expires = state.Expires
+		case errors.As(err, &precondition) || time.Now().After(expires):
+			l.err = ErrLeaseLost
+		}
+		l.mu.Unlock()
+		if l.err != nil {
+			return
+		}
+	}
+}
+
+// Unlock releases the lock. The lock object is kept, without an owner, so
+// the fencing tokens of the next leases keep increasing. It returns
+// ErrLeaseLost if the lease was lost.
+func (l *Lease) Unlock() error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil || l.released {
+		return l.err
+	}
+	l.released = true
+	_, err := l.fss3.writeLock(context.Background(), l.key, lockState{Token: l.token}, l.etag)
+	var precondition ErrPreconditionFailed
+	if errors.As(err, &precondition) {
+		return ErrLeaseLost
+	}
+	return err
+}
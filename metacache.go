@@ -0,0 +1,194 @@
+package fss3
+
+import (
+	"container/list"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metaKey identifies a metaCache entry: the FileInfo of a name, or the
+// listing of a directory.
+type metaKey struct {
+	name string
+	list bool
+}
+
+// metaEntry is a FileInfo or a directory listing held by a metaCache.
+type metaEntry struct {
+	key     metaKey
+	info    FileInfo
+	ents    []FileInfo
+	expires time.Time
+	elem    *list.Element
+}
+
+// metaCache is an in-memory LRU cache of FileInfos and directory listings
+// that expire after a TTL.
+type metaCache struct {
+	ttl time.Duration
+	max int
+
+	mu      sync.Mutex
+	entries map[metaKey]*metaEntry
+	lru     *list.List
+}
+
+// newMetaCache creates a cache holding at most max entries for ttl.
+func newMetaCache(ttl time.Duration, max int) *metaCache {
+	return &metaCache{
+		ttl:     ttl,
+		max:     max,
+		entries: make(map[metaKey]*metaEntry),
+		lru:     list.New(),
+	}
+}
+
+// get returns the unexpired entry for key.
+func (c *metaCache) get(key metaKey) (*metaEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		c.drop(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e.elem)
+	return e, true
+}
+
+// put adds e to the cache and evicts the least recently used entries to stay
+// within the size cap.
+func (c *metaCache) put(e *metaEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[e.key]; ok {
+		c.drop(old)
+	}
+	e.expires = time.Now().Add(c.ttl)
+	e.elem = c.lru.PushFront(e)
+	c.entries[e.key] = e
+	for c.lru.Len() > c.max {
+		c.drop(c.lru.Back().Value.(*metaEntry))
+	}
+}
+
+func (c *metaCache) drop(e *metaEntry) {
+	delete(c.entries, e.key)
+	c.lru.Remove(e.elem)
+}
+
+// stat returns a copy of the cached FileInfo of name.
+func (c *metaCache) stat(name string) (*FileInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	e, ok := c.get(metaKey{name: name})
+	if !ok {
+		return nil, false
+	}
+	fi := e.info
+	return &fi, true
+}
+
+// putStat caches the FileInfo of name.
+func (c *metaCache) putStat(name string, fi *FileInfo) {
+	if c == nil {
+		return
+	}
+	c.put(&metaEntry{key: metaKey{name: name}, info: *fi})
+}
+
+// list returns copies of the cached entries of the directory name.
+func (c *metaCache) list(name string) ([]*FileInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	e, ok := c.get(metaKey{name: name, list: true})
+	if !ok {
+		return nil, false
+	}
+	ents := make([]*FileInfo, len(e.ents))
+	for i := range e.ents {
+		fi := e.ents[i]
+		ents[i] = &fi
+	}
+	return ents, true
+}
+
+// putList caches the entries of the directory name.
+func (c *metaCache) putList(name string, ents []*FileInfo) {
+	if c == nil {
+		return
+	}
+	e := &metaEntry{key: metaKey{name: name, list: true}, ents: make([]FileInfo, len(ents))}
+	for i, fi := range ents {
+		e.ents[i] = *fi
+	}
+	c.put(e)
+}
+
+// dropKey drops the entry for key, if any.
+func (c *metaCache) dropKey(key metaKey) {
+	if e, ok := c.entries[key]; ok {
+		c.drop(e)
+	}
+}
+
+// invalidate drops the entries of name and the listing of its parent
+// directory. Higher directories are only dropped while the existence of the
+// one below may have changed: a directory whose cached listing held other
+// entries exists before and after the change.
+func (c *metaCache) invalidate(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropKey(metaKey{name: name})
+	c.dropKey(metaKey{name: name, list: true})
+	for name != rootName {
+		parent := sanitizeName(path.Dir(name))
+		e, ok := c.entries[metaKey{name: parent, list: true}]
+		stable := ok && len(e.ents) > 1 && time.Now().Before(e.expires)
+		c.dropKey(metaKey{name: parent})
+		c.dropKey(metaKey{name: parent, list: true})
+		if stable {
+			break
+		}
+		name = parent
+	}
+}
+
+// invalidateTree is like invalidate but also drops the entries of
+// everything the directory name contains.
+func (c *metaCache) invalidateTree(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	for key, e := range c.entries {
+		if name == rootName || strings.HasPrefix(key.name, name+"/") {
+			c.drop(e)
+		}
+	}
+	c.mu.Unlock()
+	c.invalidate(name)
+}
+
+// invalidate drops the cached contents and metadata of the object at key.
+func (fss3 *FSS3) invalidate(key string) {
+	fss3.cache.invalidate(key)
+	if fss3.meta == nil {
+		return
+	}
+	name, ok := fss3.markerDir(key)
+	if !ok {
+		name = fss3.keyToName(key)
+	}
+	fss3.meta.invalidate(name)
+}
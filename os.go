@@ -2,13 +2,15 @@ package fss3
 
 import (
 	"bytes"
-	"fmt"
+	"context"
+	"errors"
 	"io"
 	"io/fs"
 	"io/ioutil"
-	"log"
+	"iter"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Open opens a S3 object using the given name.
@@ -23,69 +25,53 @@ func (fss3 *FSS3) Open(name string) (*File, error) {
 	}
 
 	// Set the initial isDir to the root directory key
-	isDir := name == fss3.cfg.DirFileName
-	stat, err := fss3.statObject(name, nil)
-	if err != nil {
-		// Check if the requested path is a directory
-		rspErr := errToRspErr(err)
-		if rspErr.Code == "NoSuchKey" {
-			isDir = true
-			// Check if name is the root path
-			if name != fss3.cfg.DirFileName {
-				name = name + "/" + fss3.cfg.DirFileName
-			}
-			dirStat, dirErr := fss3.statObject(name, nil)
-			if dirErr != nil {
+	isDir := name == rootName
+	var stat objectInfo
+	var err error
+	cached := false
+	if !isDir {
+		stat, cached = fss3.cache.fresh(fss3.nameToKey(name))
+	}
+	if !isDir && !cached {
+		stat, err = fss3.statObject(fss3.nameToKey(name), nil)
+		if err != nil {
+			// Check if the requested path is a directory
+			if errToRspErr(err).Code != "NoSuchKey" {
 				return nil, minioErrToPathErr(err)
 			}
-			stat = dirStat
-		} else {
-			return nil, minioErrToPathErr(err)
+			isDir = true
 		}
 	}
-
-	obj, err := fss3.getObject(name, nil)
-	if err != nil {
-		return nil, minioErrToPathErr(err)
+	if isDir {
+		dirStat, dirErr := fss3.statDir(name)
+		if dirErr != nil {
+			if err == nil {
+				err = dirErr
+			}
+			return nil, minioErrToPathErr(err)
+		}
+		stat = dirStat
 	}
 
-	fileInfo := FileInfo{
-		info:    &stat,
-		size:    stat.Size,
-		modTime: stat.LastModified,
-	}
-	// If directory, get the last modified time and calculate the size
-	if isDir && strings.HasSuffix(name, fss3.cfg.DirFileName) {
-		var prefix string
-		if name == fss3.cfg.DirFileName {
-			prefix = ""
-		} else {
-			prefix = name[:len(name)-len(fss3.cfg.DirFileName)]
-		}
-		opts := listObjectsOptions{
-			Recursive:    true,
-			Prefix:       prefix,
-			WithMetadata: true,
-		}
-		for obj := range fss3.listObjects(&opts) {
-			if obj.Err != nil {
-				log.Printf("warning: %s", obj.Err)
-				continue
-			}
-			fileInfo.size += obj.Size
-			if obj.LastModified.After(fileInfo.modTime) {
-				fileInfo.modTime = obj.LastModified
-			}
+	var obj io.ReadCloser
+	if !isDir {
+		obj, err = fss3.openObject(stat)
+		if err != nil {
+			return nil, minioErrToPathErr(err)
 		}
 	}
 
+	fileInfo := fss3.newFileInfo(stat)
+	fileInfo.size = stat.Size
+
 	f := FS{
 		fss3: fss3,
 	}
 	file := File{
 		fs:       &f,
+		name:     name,
 		obj:      obj,
-		fileInfo: &fileInfo,
+		fileInfo: fileInfo,
 	}
 
 	return &file, nil
@@ -93,6 +79,10 @@ func (fss3 *FSS3) Open(name string) (*File, error) {
 
 // Stat returns a fs.FileInfo describing the named object.
 func (fss3 *FSS3) Stat(name string) (fs.FileInfo, error) {
+	name = sanitizeName(name)
+	if fi, ok := fss3.meta.stat(name); ok {
+		return fi, nil
+	}
 	ff, err := fss3.Open(name)
 	if err != nil {
 		return nil, err
@@ -102,6 +92,7 @@ func (fss3 *FSS3) Stat(name string) (fs.FileInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	fss3.meta.putStat(name, stat.(*FileInfo))
 	return stat, nil
 }
 
@@ -121,35 +112,45 @@ func (fss3 *FSS3) ReadFile(name string) ([]byte, error) {
 
 // ReadDir returns a directory listing.
 func (fss3 *FSS3) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fss3.ReadDirContext(context.Background(), name)
+}
+
+// ReadDirContext is like ReadDir but stops when ctx is done.
+func (fss3 *FSS3) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	name = sanitizeName(name)
+	if infos, ok := fss3.meta.list(name); ok {
+		ents := make([]fs.DirEntry, len(infos))
+		for i, fi := range infos {
+			ents[i] = &DirEntry{info: fi}
+		}
+		return ents, nil
+	}
 	ff, err := fss3.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer ff.Close()
-	return ff.ReadDir(0)
+	ents, err := ff.ReadDirContext(ctx, 0)
+	if err != nil || fss3.meta == nil {
+		return ents, err
+	}
+	infos := make([]*FileInfo, len(ents))
+	for i, ent := range ents {
+		infos[i] = ent.(*DirEntry).info
+		fss3.meta.putStat(fss3.joinName(name, ent.Name()), infos[i])
+	}
+	fss3.meta.putList(name, infos)
+	return ents, nil
 }
 
 // Create creates or truncates the named object.
 // The object is created with mode 0666 (before umask).
 func (fss3 *FSS3) Create(name string) (*File, error) {
-	buf := bytes.NewBuffer([]byte{})
-	key := sanitizeName(name)
-	parent := sanitizeName(filepath.Dir(key))
-
-	err := fss3.MkdirAll(parent, umask(fss3.cfg.Umask, fs.ModeDir|fs.ModePerm))
+	name = sanitizeName(name)
+	err := fss3.writeFrom(name, bytes.NewReader(nil), 0, 0666, time.Time{})
 	if err != nil {
 		return nil, err
 	}
-	opts := putObjectOptions{
-		UserMetadata: map[string]string{
-			"mode": fmt.Sprintf("%o", umask(fss3.cfg.Umask, 0666)),
-		},
-		ContentType: guessContentType(name),
-	}
-	_, err = fss3.putObject(key, buf, int64(buf.Len()), &opts)
-	if err != nil {
-		return nil, minioErrToPathErr(err)
-	}
 
 	f, err := fss3.Open(name)
 	if err != nil {
@@ -160,33 +161,49 @@ func (fss3 *FSS3) Create(name string) (*File, error) {
 }
 
 // Mkdir creates a new directory with the specified name and permission bits.
+// Nothing is stored for directories with MarkerNone.
 func (fss3 *FSS3) Mkdir(name string, mode fs.FileMode) error {
 	name = sanitizeName(name)
-	key := name
-	buf := bytes.NewBuffer([]byte{})
-	if name != fss3.cfg.DirFileName {
-		key = name + "/" + fss3.cfg.DirFileName
+	key := fss3.dirKey(name)
+	if key == "" {
+		fss3.rememberDir(name)
+		fss3.meta.invalidate(name)
+		return nil
 	}
 
+	buf := bytes.NewBuffer([]byte{})
 	opts := putObjectOptions{
-		UserMetadata: map[string]string{
-			"mode": fmt.Sprintf("%o", umask(fss3.cfg.Umask, mode|fs.ModeDir)),
-		},
+		UserMetadata: fss3.objectMetadata(umask(fss3.cfg.Umask, mode|fs.ModeDir), time.Time{}),
 	}
 	_, err := fss3.putObject(key, buf, int64(buf.Len()), &opts)
 	if err != nil {
 		return minioErrToPathErr(err)
 	}
+	fss3.rememberDir(name)
 
 	return nil
 }
 
-// MkdirAll creates a directory named path, along with any necessary parents,
+// MkdirAll creates a directory named path, along with any necessary parents.
+// Directories that already exist are left untouched.
 func (fss3 *FSS3) MkdirAll(name string, mode fs.FileMode) error {
 	name = sanitizeName(name)
-	tokens := append([]string{fss3.cfg.DirFileName}, strings.Split(name, "/")...)
-	for i := range tokens {
-		err := fss3.Mkdir(strings.Join(tokens[:i+1], "/"), mode)
+	var missing []string
+	for dir := name; ; dir = sanitizeName(filepath.Dir(dir)) {
+		exists, err := fss3.dirExists(dir)
+		if err != nil {
+			return err
+		}
+		if exists {
+			break
+		}
+		missing = append(missing, dir)
+		if dir == rootName {
+			break
+		}
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		err := fss3.Mkdir(missing[i], mode)
 		if err != nil {
 			return err
 		}
@@ -194,105 +211,288 @@ func (fss3 *FSS3) MkdirAll(name string, mode fs.FileMode) error {
 	return nil
 }
 
+// mkdirParents creates the missing parent directories of a written object,
+// unless disabled by Config.SkipParentDirs.
+func (fss3 *FSS3) mkdirParents(parent string) error {
+	if fss3.cfg.SkipParentDirs {
+		return nil
+	}
+	return fss3.MkdirAll(parent, fs.ModePerm)
+}
+
 // Remove removes the named file or directory.
 // If directory is not empty, it returns an error.
 func (fss3 *FSS3) Remove(name string) error {
-	name = sanitizeName(name)
-	dirName := name
-	if name != fss3.cfg.DirFileName {
-		dirName = name + "/" + fss3.cfg.DirFileName
+	return fss3.RemoveWith(name, nil)
+}
+
+// RemoveWith is like Remove with the given options.
+func (fss3 *FSS3) RemoveWith(name string, ropts *RemoveOptions) error {
+	if ropts == nil {
+		ropts = &RemoveOptions{}
 	}
+	name = sanitizeName(name)
 
-	_, err := fss3.statObject(name, nil)
+	_, err := fss3.statObject(fss3.nameToKey(name), nil)
 	if err != nil {
 		rspErr := errToRspErr(err)
-		if rspErr.Code == "NoSuchKey" {
-			_, dirErr := fss3.statObject(dirName, nil)
-			if dirErr != nil {
-				return minioErrToPathErr(err)
-			}
-			opts := listObjectsOptions{
-				Recursive: false,
-				Prefix:    name,
-			}
-			objs := make([]objectInfo, 0)
-			for obj := range fss3.listObjects(&opts) {
-				objs = append(objs, obj)
+		if rspErr.Code != "NoSuchKey" {
+			return minioErrToPathErr(err)
+		}
+		if _, dirErr := fss3.statDir(name); dirErr != nil {
+			return minioErrToPathErr(err)
+		}
+		dirKey := fss3.dirKey(name)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := listObjectsOptions{
+			Recursive: false,
+			Prefix:    fss3.dirPrefix(name),
+		}
+		for obj := range fss3.listObjectsContext(ctx, &opts) {
+			if obj.Err != nil {
+				return minioErrToPathErr(obj.Err)
 			}
-			if len(objs) > 1 || (len(objs) != 1 && objs[0].Key != dirName) {
+			if obj.Key != dirKey {
 				return &fs.PathError{
 					Op:   "remove",
 					Path: name,
 					Err:  ErrNotEmpty{name: name},
 				}
 			}
-			dirErr = fss3.removeObject(dirName, nil)
-			if dirErr != nil {
-				return minioErrToPathErr(dirErr)
+		}
+		if dirKey != "" {
+			if err := fss3.removeKey(name, dirKey, ropts); err != nil {
+				return err
 			}
-			return nil
 		}
-		return minioErrToPathErr(err)
+		fss3.forgetDirs(name)
+		fss3.meta.invalidate(name)
+		return nil
 	}
 
-	err = fss3.removeObject(name, nil)
+	return fss3.removeKey(name, fss3.nameToKey(name), ropts)
+}
+
+// removeKey removes the object at key, the file or directory marker of name,
+// as configured by ropts.
+func (fss3 *FSS3) removeKey(name, key string, ropts *RemoveOptions) error {
+	switch {
+	case fss3.trashed(ropts):
+		return fss3.moveToTrash(name, []string{key}, ropts.Progress)
+	case ropts.AllVersions:
+		err := fss3.removeBatched(fss3.exactObjects(key, true), ropts.Progress)
+		// Batch removals bypass removeObject.
+		fss3.invalidate(key)
+		return err
+	}
+	err := fss3.removeObject(key, nil)
+	if ropts.Progress != nil {
+		if err != nil {
+			ropts.Progress(0, 1)
+		} else {
+			ropts.Progress(1, 0)
+		}
+	}
 	if err != nil {
 		return minioErrToPathErr(err)
 	}
-
 	return nil
 }
 
-// RemoveAll removes path and any children it contains.
+// RemoveOptions configures RemoveWith and RemoveAllWith.
+type RemoveOptions struct {
+	// AllVersions also removes the noncurrent versions and delete markers
+	// of the objects in versioned buckets. The objects are removed
+	// permanently.
+	AllVersions bool
+	// Permanent removes the objects even if Config.Trash is set.
+	Permanent bool
+	// Progress, if set, is called as RemoveWith and RemoveAllWith go with
+	// the number of objects removed, or moved to the trash, and failed so
+	// far.
+	Progress func(removed, failed int)
+}
+
+// RemoveAll removes path and any children it contains. Every object is
+// attempted, and the errors of the listing and of all the objects that
+// couldn't be removed are joined.
 func (fss3 *FSS3) RemoveAll(path string) error {
-	name := sanitizeName(path)
-	prefix := name
-	if name == fss3.cfg.DirFileName {
-		prefix = ""
-	} else {
-		prefix = name[:len(name)-len(fss3.cfg.DirFileName)]
-	}
-	objsCh := make(chan objectInfo)
+	return fss3.RemoveAllWith(path, nil)
+}
 
-	go func() {
-		defer close(objsCh)
-		opts := listObjectsOptions{
-			Recursive: true,
-			Prefix:    prefix,
+// removeBatch is the number of objects removed per request.
+const removeBatch = 1000
+
+// RemoveAllWith is like RemoveAll with the given options.
+func (fss3 *FSS3) RemoveAllWith(path string, ropts *RemoveOptions) error {
+	if ropts == nil {
+		ropts = &RemoveOptions{}
+	}
+	name := sanitizeName(path)
+	prefix := fss3.dirPrefix(name)
+	fss3.forgetDirs(name)
+	// Batch removals bypass removeObject, so the caches are invalidated here
+	// once they're done.
+	defer func() {
+		fss3.cache.invalidatePrefix(prefix)
+		if name != rootName {
+			fss3.cache.invalidate(fss3.nameToKey(name))
 		}
-		for obj := range fss3.listObjects(&opts) {
+		fss3.meta.invalidateTree(name)
+	}()
+
+	if fss3.trashed(ropts) {
+		var keys []string
+		for obj := range fss3.removeAllObjects(name, false) {
 			if obj.Err != nil {
-				log.Printf("warning: %s", obj.Err)
+				return minioErrToPathErr(obj.Err)
 			}
+			keys = append(keys, obj.Key)
+		}
+		return fss3.moveToTrash(name, keys, ropts.Progress)
+	}
+
+	return fss3.removeBatched(fss3.removeAllObjects(name, ropts.AllVersions), ropts.Progress)
+}
+
+// removeBatched removes the objects of objs, removeBatch at a time. Entries
+// with Err set are reported without stopping the removal. progress, if not
+// nil, is called after each batch with the number of objects removed and
+// failed so far. The errors are joined.
+func (fss3 *FSS3) removeBatched(objs iter.Seq[objectInfo], progress func(removed, failed int)) error {
+	var errs []error
+	var removed, failed int
+	batch := make([]objectInfo, 0, removeBatch)
+	flush := func() {
+		objsCh := make(chan objectInfo, len(batch))
+		for _, obj := range batch {
 			objsCh <- obj
 		}
-	}()
+		close(objsCh)
+		var rerrs []removeObjectError
+		var batchErr error
+		// The errors are drained so the removal goroutine always exits.
+		for rerr := range fss3.removeObjects(objsCh, nil) {
+			if rerr.ObjectName == "" {
+				batchErr = rerr.Err
+				continue
+			}
+			rerrs = append(rerrs, rerr)
+		}
+		// Failed requests are reported without an object name: every object
+		// of the batch failed.
+		if batchErr != nil {
+			rerrs = rerrs[:0]
+			for _, obj := range batch {
+				rerrs = append(rerrs, removeObjectError{ObjectName: obj.Key, Err: batchErr})
+			}
+		}
+		for _, rerr := range rerrs {
+			errs = append(errs, &fs.PathError{Op: "remove", Path: fss3.keyToName(rerr.ObjectName), Err: rerr.Err})
+		}
+		removed += len(batch) - len(rerrs)
+		failed += len(rerrs)
+		if progress != nil {
+			progress(removed, failed)
+		}
+		batch = batch[:0]
+	}
+	for obj := range objs {
+		if obj.Err != nil {
+			errs = append(errs, minioErrToPathErr(obj.Err))
+			continue
+		}
+		batch = append(batch, obj)
+		if len(batch) == removeBatch {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+	return errors.Join(errs...)
+}
 
-	errCh := fss3.removeObjects(objsCh, nil)
-	for err := range errCh {
-		return minioErrToPathErr(err.Err)
+// removeAllObjects returns an iterator over the objects removed by
+// RemoveAll: the object at name, if any, and the objects under it. Listing
+// errors are yielded as entries with Err set.
+func (fss3 *FSS3) removeAllObjects(name string, versions bool) iter.Seq[objectInfo] {
+	return func(yield func(objectInfo) bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if name != rootName {
+			for obj := range fss3.exactObjects(fss3.nameToKey(name), versions) {
+				if !yield(obj) {
+					return
+				}
+			}
+		}
+		opts := listObjectsOptions{
+			Recursive:    true,
+			Prefix:       fss3.dirPrefix(name),
+			WithVersions: versions,
+		}
+		for obj := range fss3.listObjectsContext(ctx, &opts) {
+			if !yield(obj) {
+				return
+			}
+		}
 	}
+}
 
-	return nil
+// exactObjects returns an iterator over the object at key, or all its
+// versions, without the objects whose keys only start with key. Listing
+// errors are yielded as entries with Err set.
+func (fss3 *FSS3) exactObjects(key string, versions bool) iter.Seq[objectInfo] {
+	return func(yield func(objectInfo) bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := listObjectsOptions{Prefix: key, WithVersions: versions}
+		for obj := range fss3.listObjectsContext(ctx, &opts) {
+			if (obj.Err != nil || obj.Key == key) && !yield(obj) {
+				return
+			}
+		}
+	}
 }
 
-func (fss3 *FSS3) writeFrom(name string, r io.Reader, size int64, perm fs.FileMode) error {
+func (fss3 *FSS3) writeFrom(name string, r io.Reader, size int64, perm fs.FileMode, modTime time.Time) error {
 	name = sanitizeName(name)
 	parent := sanitizeName(filepath.Dir(name))
-	err := fss3.MkdirAll(parent, fs.ModePerm)
+	err := fss3.mkdirParents(parent)
 	if err != nil {
 		return err
 	}
+	return fss3.putFile(name, r, size, perm, modTime)
+}
+
+// putFile uploads the contents of r to the named object without creating
+// any parent directories.
+func (fss3 *FSS3) putFile(name string, r io.Reader, size int64, perm fs.FileMode, modTime time.Time) error {
+	return fss3.putFileWith(name, r, size, perm, modTime, nil)
+}
 
+// putFileWith is like putFile but only writes if the conditions of wopts
+// hold.
+func (fss3 *FSS3) putFileWith(name string, r io.Reader, size int64, perm fs.FileMode, modTime time.Time, wopts *WriteOptions) error {
 	opts := putObjectOptions{
-		UserMetadata: map[string]string{
-			"mode": fmt.Sprintf("%o", umask(fss3.cfg.Umask, perm)),
-		},
-		ContentType: guessContentType(name),
+		UserMetadata: fss3.objectMetadata(umask(fss3.cfg.Umask, perm), modTime),
+		ContentType:  guessContentType(name),
 	}
-	_, err = fss3.putObject(name, r, size, &opts)
+	if fss3.cfg.Checksum != ChecksumNone {
+		var cleanup func()
+		var err error
+		r, size, cleanup, err = fss3.withChecksum(r, &opts)
+		if err != nil {
+			return &fs.PathError{Op: "write", Path: name, Err: err}
+		}
+		defer cleanup()
+	}
+	ctx := wopts.context(context.Background())
+	_, err := fss3.putObjectContext(ctx, fss3.nameToKey(name), r, size, &opts)
 	if err != nil {
-		return minioErrToPathErr(err)
+		return writeErr(name, err)
 	}
 
 	return nil
@@ -301,18 +501,23 @@ func (fss3 *FSS3) writeFrom(name string, r io.Reader, size int64, perm fs.FileMo
 // WriteFile writes data to an object and creates any necessary parent.
 // It creates the file if it doesn't exist.
 func (fss3 *FSS3) WriteFile(name string, data []byte, perm fs.FileMode) error {
-	return fss3.writeFrom(name, bytes.NewReader(data), int64(len(data)), perm)
+	return fss3.WriteFileWith(name, data, perm, nil)
 }
 
-// WriteFrom writes the contents of reader to an object.
-func (fss3 *FSS3) WriteFrom(name string, r io.Reader, perm fs.FileMode) error {
-	return fss3.writeFrom(name, r, -1, perm)
+// WriteFileWith is like WriteFile but only writes if the conditions of opts
+// hold. Otherwise it returns ErrPreconditionFailed.
+func (fss3 *FSS3) WriteFileWith(name string, data []byte, perm fs.FileMode, opts *WriteOptions) error {
+	name = sanitizeName(name)
+	err := fss3.mkdirParents(sanitizeName(filepath.Dir(name)))
+	if err != nil {
+		return err
+	}
+	return fss3.putFileWith(name, bytes.NewReader(data), int64(len(data)), perm, time.Time{}, opts)
 }
 
-// WalkDir walks the file tree rooted at root, calling walkFn for each file or
-// directory in the tree, including root.
-func (fss3 *FSS3) WalkDir(root string, fn fs.WalkDirFunc) error {
-	return fs.WalkDir(fss3.FS(), root, fn)
+// WriteFrom writes the contents of reader to an object.
+func (fss3 *FSS3) WriteFrom(name string, r io.Reader, perm fs.FileMode) error {
+	return fss3.writeFrom(name, r, -1, perm, time.Time{})
 }
 
 // Chmod changes the mode of the named file to mode.
@@ -325,15 +530,96 @@ func (fss3 *FSS3) Chmod(name string, mode fs.FileMode) error {
 	if info.Mode() == mode {
 		return nil
 	}
+	key := fss3.nameToKey(name)
+	if info.IsDir() {
+		key = fss3.dirKey(name)
+		if key == "" {
+			return &fs.PathError{
+				Op:   "chmod",
+				Path: name,
+				Err:  errors.ErrUnsupported,
+			}
+		}
+	}
 	dst := copyDestOptions{
 		ReplaceMetadata: true,
-		UserMetadata: map[string]string{
-			"mode": fmt.Sprintf("%o", umask(fss3.cfg.Umask, mode)),
-		},
+		UserMetadata:    fss3.objectMetadata(umask(fss3.cfg.Umask, mode), info.ModTime()),
+	}
+	if sum, ok := info.(*FileInfo).info.UserMetadata["Checksum"]; ok {
+		dst.UserMetadata["checksum"] = sum
 	}
-	_, err = fss3.copyObject(name, name, nil, &dst)
+	_, err = fss3.copyObject(key, key, nil, &dst)
 	if err != nil {
 		return minioErrToPathErr(err)
 	}
 	return nil
 }
+
+// Copy copies the named object to dst. Directories are copied along with
+// everything they contain.
+func (fss3 *FSS3) Copy(src, dst string) error {
+	src = sanitizeName(src)
+	dst = sanitizeName(dst)
+	info, err := fss3.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		parent := sanitizeName(filepath.Dir(dst))
+		err = fss3.mkdirParents(parent)
+		if err != nil {
+			return err
+		}
+		_, err = fss3.copyObjectSize(fss3.nameToKey(src), fss3.nameToKey(dst), info.Size(), nil, nil)
+		if err != nil {
+			return minioErrToPathErr(err)
+		}
+		return nil
+	}
+
+	srcPrefix := fss3.dirPrefix(src)
+	dstPrefix := fss3.dirPrefix(dst)
+	if strings.HasPrefix(dstPrefix, srcPrefix) {
+		return &fs.PathError{
+			Op:   "copy",
+			Path: dst,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	err = fss3.MkdirAll(dst, info.Mode())
+	if err != nil {
+		return err
+	}
+	opts := listObjectsOptions{
+		Recursive: true,
+		Prefix:    srcPrefix,
+	}
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return minioErrToPathErr(obj.Err)
+		}
+		dstKey := dstPrefix + strings.TrimPrefix(obj.Key, srcPrefix)
+		_, err = fss3.copyObjectSize(obj.Key, dstKey, obj.Size, nil, nil)
+		if err != nil {
+			return minioErrToPathErr(err)
+		}
+	}
+	return nil
+}
+
+// Rename renames (moves) oldpath to newpath.
+// Objects are copied server-side and the originals are removed afterwards.
+func (fss3 *FSS3) Rename(oldpath, newpath string) error {
+	err := fss3.Copy(oldpath, newpath)
+	if err != nil {
+		return err
+	}
+	info, err := fss3.Stat(oldpath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fss3.RemoveAll(oldpath)
+	}
+	return fss3.Remove(oldpath)
+}
@@ -0,0 +1,371 @@
+package fss3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotInfo describes a snapshot taken with Snapshot.
+type SnapshotInfo struct {
+	ID string
+	// Dir is the directory the snapshot was taken of.
+	Dir     string
+	Created time.Time
+	// Files and Size are the number and total size of the files in the
+	// snapshot.
+	Files int
+	Size  int64
+}
+
+// manifestEntry is a file or directory recorded in a snapshot manifest.
+type manifestEntry struct {
+	Path    string      `json:"path"`
+	Dir     bool        `json:"dir,omitempty"`
+	ETag    string      `json:"etag,omitempty"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+	// Checksum is the checksum stored with the file, if any.
+	Checksum string `json:"checksum,omitempty"`
+	// Blob is the key of the copy of the file contents. Files with the same
+	// contents share it, so it doesn't hold their metadata.
+	Blob string `json:"blob,omitempty"`
+}
+
+// manifest is the content of a snapshot manifest object.
+type manifest struct {
+	ID      string          `json:"id"`
+	Dir     string          `json:"dir"`
+	Created time.Time       `json:"created"`
+	Entries []manifestEntry `json:"entries"`
+}
+
+func (m *manifest) info() SnapshotInfo {
+	si := SnapshotInfo{ID: m.ID, Dir: m.Dir, Created: m.Created}
+	for _, e := range m.Entries {
+		if !e.Dir {
+			si.Files++
+			si.Size += e.Size
+		}
+	}
+	return si
+}
+
+// snapshotKey returns the key of elem under Config.SnapshotPrefix.
+func (fss3 *FSS3) snapshotKey(elem string) string {
+	return fss3.nameToKey(fss3.joinName(sanitizeName(fss3.cfg.SnapshotPrefix), elem))
+}
+
+// manifestKey returns the key of the manifest of the snapshot id.
+func (fss3 *FSS3) manifestKey(id string) string {
+	return fss3.snapshotKey("manifests/" + id + ".json")
+}
+
+// blobKey returns the key of the copy of the contents with etag and size.
+// Files with the same contents share their copy.
+func (fss3 *FSS3) blobKey(etag string, size int64) string {
+	return fss3.snapshotKey(fmt.Sprintf("blobs/%s-%d", strings.Trim(etag, "\""), size))
+}
+
+// blobs returns the keys of the stored content copies.
+func (fss3 *FSS3) blobs() (map[string]bool, error) {
+	blobs := make(map[string]bool)
+	opts := listObjectsOptions{
+		Prefix:    fss3.snapshotKey("blobs") + "/",
+		Recursive: true,
+	}
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return nil, minioErrToPathErr(obj.Err)
+		}
+		blobs[obj.Key] = true
+	}
+	return blobs, nil
+}
+
+// Snapshot records the tree under dir and returns the ID of the snapshot.
+// It doesn't rely on bucket versioning: the key, ETag, size, mode and
+// modification time of every entry are written to a manifest object, and
+// the contents of the files are copied server-side under
+// Config.SnapshotPrefix. Contents already copied by a previous snapshot are
+// referenced instead of copied again, so only the files modified since
+// cost storage.
+func (fss3 *FSS3) Snapshot(dir string) (string, error) {
+	dir = sanitizeName(dir)
+	if _, err := fss3.statDir(dir); err != nil {
+		return "", minioErrToPathErr(err)
+	}
+	files, dirs, err := fss3.remoteEntries(dir)
+	if err != nil {
+		return "", err
+	}
+	blobs, err := fss3.blobs()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	id, err := newID(now)
+	if err != nil {
+		return "", err
+	}
+	m := manifest{ID: id, Dir: dir, Created: now}
+	for rel, fi := range dirs {
+		if fi.info.ETag != "" {
+			if err := fss3.fillMetadata(fi); err != nil {
+				return "", minioErrToPathErr(err)
+			}
+		}
+		info := fss3.dirInfo(fss3.joinName(dir, rel), *fi.info)
+		m.Entries = append(m.Entries, manifestEntry{
+			Path:    rel,
+			Dir:     true,
+			Mode:    fss3.newFileInfo(info).Mode(),
+			ModTime: fi.ModTime(),
+		})
+	}
+
+	var mu sync.Mutex
+	rels := make([]string, 0, len(files))
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	err = forEach(rels, fss3.cfg.Concurrency, func(rel string) error {
+		fi := files[rel]
+		// Without its metadata, the file would be recorded with mode 0.
+		if err := fss3.fillMetadata(fi); err != nil {
+			return minioErrToPathErr(err)
+		}
+		e := manifestEntry{
+			Path:     rel,
+			ETag:     fi.ETag(),
+			Size:     fi.Size(),
+			Mode:     fi.Mode(),
+			ModTime:  fi.ModTime(),
+			Checksum: fi.info.UserMetadata["Checksum"],
+			Blob:     fss3.blobKey(fi.info.ETag, fi.Size()),
+		}
+		mu.Lock()
+		copied := blobs[e.Blob]
+		blobs[e.Blob] = true
+		mu.Unlock()
+		if !copied {
+			// The copy fails if the file changed since it was listed.
+			src := copySrcOptions{Object: fi.info.Key, MatchETag: e.ETag}
+			if _, err := fss3.copyObjectSize(fi.info.Key, e.Blob, e.Size, &src, nil); err != nil {
+				mu.Lock()
+				delete(blobs, e.Blob)
+				mu.Unlock()
+				return minioErrToPathErr(err)
+			}
+		}
+		mu.Lock()
+		m.Entries = append(m.Entries, e)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return "", err
+	}
+	opts := putObjectOptions{ContentType: "application/json"}
+	if _, err := fss3.putObject(fss3.manifestKey(m.ID), bytes.NewReader(data), int64(len(data)), &opts); err != nil {
+		return "", minioErrToPathErr(err)
+	}
+	return m.ID, nil
+}
+
+// readManifest returns the manifest of the snapshot id.
+func (fss3 *FSS3) readManifest(id string) (*manifest, error) {
+	obj, err := fss3.getObject(fss3.manifestKey(id), nil)
+	if err != nil {
+		return nil, minioErrToPathErr(err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		if isNotExist(err) {
+			return nil, &fs.PathError{Op: "snapshot", Path: id, Err: fs.ErrNotExist}
+		}
+		return nil, minioErrToPathErr(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListSnapshots returns the snapshots, oldest first.
+func (fss3 *FSS3) ListSnapshots() ([]SnapshotInfo, error) {
+	var snapshots []SnapshotInfo
+	err := fss3.manifests(func(m *manifest) {
+		snapshots = append(snapshots, m.info())
+	})
+	return snapshots, err
+}
+
+// manifests calls fn with each snapshot manifest, oldest first.
+func (fss3 *FSS3) manifests(fn func(*manifest)) error {
+	prefix := fss3.snapshotKey("manifests") + "/"
+	opts := listObjectsOptions{Prefix: prefix}
+	var ids []string
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return minioErrToPathErr(obj.Err)
+		}
+		if id, ok := strings.CutSuffix(strings.TrimPrefix(obj.Key, prefix), ".json"); ok {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		m, err := fss3.readManifest(id)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted since listed.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fn(m)
+	}
+	return nil
+}
+
+// Restore brings the tree under dir back to the snapshot id. dir doesn't
+// have to be the directory the snapshot was taken of. Files and directories
+// recorded in the snapshot are copied back and given the mode and
+// modification time they had when it was taken, and the ones created since
+// are removed. The errors of all the entries that couldn't be restored are
+// joined.
+func (fss3 *FSS3) Restore(id, dir string) error {
+	dir = sanitizeName(dir)
+	m, err := fss3.readManifest(id)
+	if err != nil {
+		return err
+	}
+	files, dirs, err := fss3.remoteEntries(dir)
+	if err != nil {
+		return err
+	}
+	if err := fss3.MkdirAll(dir, fss3.cfg.DirMode); err != nil {
+		return err
+	}
+
+	recorded := make(map[string]bool, len(m.Entries))
+	var errs []error
+	// Parents sort before their children, so directories are created first.
+	for _, e := range m.Entries {
+		recorded[e.Path] = true
+		if !e.Dir {
+			continue
+		}
+		name := fss3.joinName(dir, e.Path)
+		if err := fss3.Mkdir(name, e.Mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err = forEach(m.Entries, fss3.cfg.Concurrency, func(e manifestEntry) error {
+		if e.Dir {
+			return nil
+		}
+		if fi, ok := files[e.Path]; ok && fi.ETag() == e.ETag {
+			fss3.fillMetadata(fi)
+			if fi.Mode() == e.Mode && fi.ModTime().Equal(e.ModTime) {
+				return nil
+			}
+		}
+		name := fss3.joinName(dir, e.Path)
+		meta := fss3.objectMetadata(e.Mode, e.ModTime)
+		if e.Checksum != "" {
+			meta["checksum"] = e.Checksum
+		}
+		meta["Content-Type"] = guessContentType(name)
+		dst := copyDestOptions{ReplaceMetadata: true, UserMetadata: meta}
+		if _, err := fss3.copyObjectSize(e.Blob, fss3.nameToKey(name), e.Size, nil, &dst); err != nil {
+			return minioErrToPathErr(err)
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// Remove what was created since the snapshot.
+	var extra []string
+	for rel, fi := range files {
+		if !recorded[rel] {
+			extra = append(extra, fi.info.Key)
+		}
+	}
+	for rel, fi := range dirs {
+		if !recorded[rel] && fi.info.ETag != "" {
+			extra = append(extra, fi.info.Key)
+			fss3.forgetDirs(fss3.joinName(dir, rel))
+		}
+	}
+	err = forEach(extra, fss3.cfg.Concurrency, func(key string) error {
+		if err := fss3.removeObject(key, nil); err != nil {
+			return minioErrToPathErr(err)
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	fss3.meta.invalidateTree(dir)
+	return errors.Join(errs...)
+}
+
+// DeleteSnapshot deletes the snapshot id, along with the content copies no
+// other snapshot references. It must not run concurrently with Snapshot,
+// which could reference a copy being deleted.
+func (fss3 *FSS3) DeleteSnapshot(id string) error {
+	if _, err := fss3.readManifest(id); err != nil {
+		return err
+	}
+	if err := fss3.removeObject(fss3.manifestKey(id), nil); err != nil {
+		return minioErrToPathErr(err)
+	}
+
+	referenced := make(map[string]bool)
+	err := fss3.manifests(func(m *manifest) {
+		for _, e := range m.Entries {
+			referenced[e.Blob] = true
+		}
+	})
+	if err != nil {
+		return err
+	}
+	blobs, err := fss3.blobs()
+	if err != nil {
+		return err
+	}
+	var unused []string
+	for key := range blobs {
+		if !referenced[key] {
+			unused = append(unused, key)
+		}
+	}
+	return forEach(unused, fss3.cfg.Concurrency, func(key string) error {
+		if err := fss3.removeObject(key, nil); err != nil {
+			return minioErrToPathErr(err)
+		}
+		return nil
+	})
+}
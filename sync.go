@@ -0,0 +1,507 @@
+package fss3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncCompare is a set of criteria used to decide whether a file changed.
+type SyncCompare int
+
+const (
+	// SyncSize compares file sizes.
+	SyncSize SyncCompare = 1 << iota
+	// SyncModTime compares modification times.
+	SyncModTime
+	// SyncChecksum compares MD5 digests against object ETags.
+	SyncChecksum
+)
+
+// SyncOptions configures Sync and SyncToDir.
+type SyncOptions struct {
+	// Compare selects the criteria used to detect changed files.
+	// Defaults to SyncSize|SyncModTime. Modification times are compared by
+	// content for source files without one.
+	Compare SyncCompare
+	// Delete removes destination files that don't exist in the source.
+	Delete bool
+	// Include limits the sync to files matching one of these globs.
+	Include []string
+	// Exclude skips files matching any of these globs.
+	Exclude []string
+	// DryRun reports the changes without applying them.
+	DryRun bool
+}
+
+// SyncReport lists the paths, relative to the synced directory, that were
+// copied, deleted or left unchanged. In dry-run mode it lists the changes
+// that would have been made.
+type SyncReport struct {
+	Copied    []string
+	Deleted   []string
+	Unchanged []string
+}
+
+// syncEntry describes a file on either side of a sync.
+type syncEntry struct {
+	size    int64
+	modTime func() time.Time
+	sum     func() (string, error)
+}
+
+func (opts *SyncOptions) compare() SyncCompare {
+	if opts.Compare == 0 {
+		return SyncSize | SyncModTime
+	}
+	return opts.Compare
+}
+
+// match reports whether the file at rel passes the include and exclude globs.
+// Globs are matched against both the relative path and the base name.
+func (opts *SyncOptions) match(rel string) bool {
+	matches := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, rel); ok {
+				return true
+			}
+			if ok, _ := path.Match(p, path.Base(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(opts.Include) > 0 && !matches(opts.Include) {
+		return false
+	}
+	return !matches(opts.Exclude)
+}
+
+// changed reports whether dst is out of date with src.
+func (opts *SyncOptions) changed(src, dst syncEntry) (bool, error) {
+	cmp := opts.compare()
+	if cmp&SyncSize != 0 && src.size != dst.size {
+		return true, nil
+	}
+	if cmp&SyncModTime != 0 {
+		srcTime := src.modTime()
+		if srcTime.IsZero() {
+			// Sources such as embed.FS have no modification times, so the
+			// contents are compared instead.
+			cmp |= SyncChecksum
+		} else if !srcTime.Equal(dst.modTime()) {
+			return true, nil
+		}
+	}
+	if cmp&SyncChecksum != 0 {
+		srcSum, err := src.sum()
+		if err != nil {
+			return false, err
+		}
+		dstSum, err := dst.sum()
+		if err != nil {
+			return false, err
+		}
+		return srcSum != dstSum, nil
+	}
+	return false, nil
+}
+
+// md5Sum returns the hex MD5 digest of the data read from r.
+func md5Sum(r io.Reader) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isMD5ETag reports whether the ETag is the MD5 digest of the object.
+// Multipart uploads have ETags of the form MD5-N.
+func isMD5ETag(etag string) bool {
+	return len(etag) == 32 && !strings.Contains(etag, "-")
+}
+
+// objectSum returns the MD5 digest of an object, downloading it when the
+// ETag isn't a plain MD5 digest.
+func (fss3 *FSS3) objectSum(info *objectInfo) (string, error) {
+	etag := strings.Trim(info.ETag, "\"")
+	if isMD5ETag(etag) {
+		return etag, nil
+	}
+	obj, err := fss3.getObject(info.Key, nil)
+	if err != nil {
+		return "", minioErrToPathErr(err)
+	}
+	defer obj.Close()
+	return md5Sum(obj)
+}
+
+// remoteEntries lists the files and directories under dir, keyed by their
+// paths relative to dir.
+func (fss3 *FSS3) remoteEntries(dir string) (map[string]*FileInfo, map[string]*FileInfo, error) {
+	files := make(map[string]*FileInfo)
+	dirs := make(map[string]*FileInfo)
+	prefix := fss3.dirPrefix(dir)
+	opts := listObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    true,
+		WithMetadata: true,
+	}
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return nil, nil, minioErrToPathErr(obj.Err)
+		}
+		oi := obj
+		if name, ok := fss3.markerDir(oi.Key); ok {
+			if name != dir {
+				dirs[relPath(dir, name)] = fss3.newFileInfo(oi)
+			}
+			continue
+		}
+		files[relPath(dir, fss3.keyToName(oi.Key))] = fss3.newFileInfo(oi)
+	}
+	// Without markers, directories are derived from the keys they contain.
+	if fss3.cfg.DirMarker != MarkerFile {
+		for rel := range files {
+			for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+				if _, ok := dirs[d]; !ok {
+					dirs[d] = fss3.newFileInfo(objectInfo{Key: prefix + fss3.nameToKey(d) + "/"})
+				}
+			}
+		}
+	}
+	return files, dirs, nil
+}
+
+// remoteEntry returns the syncEntry of a remote file.
+// Metadata missing from the listing is fetched on demand.
+func (fss3 *FSS3) remoteEntry(fi *FileInfo) syncEntry {
+	return syncEntry{
+		size: fi.info.Size,
+		modTime: func() time.Time {
+			fss3.fillMetadata(fi)
+			return fi.ModTime()
+		},
+		sum: func() (string, error) { return fss3.objectSum(fi.info) },
+	}
+}
+
+// fillMetadata fetches the metadata of fi if the listing didn't include it.
+// On error, fi is left as listed.
+func (fss3 *FSS3) fillMetadata(fi *FileInfo) error {
+	if len(fi.info.UserMetadata) != 0 {
+		return nil
+	}
+	opts := statObjectOptions{VersionID: fi.info.VersionID}
+	info, err := fss3.statObject(fi.info.Key, &opts)
+	if err != nil {
+		return err
+	}
+	fi.info = &info
+	fi.modTime = time.Time{}
+	return nil
+}
+
+// Sync uploads the new and changed files of src to the directory dir of dst.
+// Directory modes and file modes and modification times are preserved.
+func Sync(src fs.FS, dst *FSS3, dir string, opts *SyncOptions) (*SyncReport, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+	dir = sanitizeName(dir)
+	report := &SyncReport{}
+	remoteFiles, remoteDirs, err := dst.remoteEntries(dir)
+	if err != nil {
+		return report, err
+	}
+	localFiles := make(map[string]bool)
+	localDirs := make(map[string]bool)
+
+	err = fs.WalkDir(src, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			localDirs[p] = true
+			if _, ok := remoteDirs[p]; ok || p == "." || opts.DryRun {
+				return nil
+			}
+			return dst.MkdirAll(dst.joinName(dir, p), info.Mode())
+		}
+		if !opts.match(p) {
+			return nil
+		}
+		localFiles[p] = true
+		srcEntry := syncEntry{
+			size:    info.Size(),
+			modTime: info.ModTime,
+			sum: func() (string, error) {
+				f, err := src.Open(p)
+				if err != nil {
+					return "", err
+				}
+				defer f.Close()
+				return md5Sum(f)
+			},
+		}
+		if fi, ok := remoteFiles[p]; ok {
+			changed, err := opts.changed(srcEntry, dst.remoteEntry(fi))
+			if err != nil {
+				return err
+			}
+			if !changed {
+				report.Unchanged = append(report.Unchanged, p)
+				return nil
+			}
+		}
+		report.Copied = append(report.Copied, p)
+		if opts.DryRun {
+			return nil
+		}
+		f, err := src.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return dst.writeFrom(dst.joinName(dir, p), f, info.Size(), info.Mode(), info.ModTime())
+	})
+	if err != nil || !opts.Delete {
+		return report, err
+	}
+
+	var extraneous []string
+	// Directories holding files that are kept, such as excluded files, are
+	// kept too.
+	kept := make(map[string]bool)
+	for rel := range remoteFiles {
+		if !localFiles[rel] && opts.match(rel) {
+			extraneous = append(extraneous, rel)
+			continue
+		}
+		for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+			kept[d] = true
+		}
+	}
+	for rel := range remoteDirs {
+		if !localDirs[rel] && !kept[rel] {
+			extraneous = append(extraneous, rel)
+		}
+	}
+	// Remove the deepest paths first so directories are empty when removed.
+	sort.Sort(sort.Reverse(sort.StringSlice(extraneous)))
+	for _, rel := range extraneous {
+		if opts.DryRun {
+			report.Deleted = append(report.Deleted, rel)
+			continue
+		}
+		// Directories without a marker go away with their contents.
+		err := dst.Remove(dst.joinName(dir, rel))
+		var notEmpty ErrNotEmpty
+		if errors.As(err, &notEmpty) {
+			// Written to since listed; leave it.
+			continue
+		}
+		if err != nil && !isNotExist(err) {
+			return report, err
+		}
+		report.Deleted = append(report.Deleted, rel)
+	}
+	return report, nil
+}
+
+// SyncToDir downloads the new and changed objects under the directory dir of
+// src to the local directory localDir.
+// Directory modes and file modes and modification times are preserved.
+// Objects whose keys would resolve outside localDir, such as keys with ".."
+// elements, fail the sync with fs.ErrInvalid.
+func SyncToDir(src *FSS3, dir string, localDir string, opts *SyncOptions) (*SyncReport, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+	dir = sanitizeName(dir)
+	report := &SyncReport{}
+	remoteFiles, remoteDirs, err := src.remoteEntries(dir)
+	if err != nil {
+		return report, err
+	}
+
+	dirNames := make([]string, 0, len(remoteDirs))
+	for rel := range remoteDirs {
+		dirNames = append(dirNames, rel)
+	}
+	sort.Strings(dirNames)
+	if !opts.DryRun {
+		if err := os.MkdirAll(localDir, fs.ModePerm); err != nil {
+			return report, err
+		}
+		for _, rel := range dirNames {
+			src.fillMetadata(remoteDirs[rel])
+			mode := remoteDirs[rel].Mode().Perm()
+			if mode == 0 {
+				mode = fs.ModePerm
+			}
+			local, err := localJoin(localDir, rel)
+			if err != nil {
+				return report, err
+			}
+			if err := os.MkdirAll(local, mode); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	fileNames := make([]string, 0, len(remoteFiles))
+	for rel := range remoteFiles {
+		if opts.match(rel) {
+			fileNames = append(fileNames, rel)
+		}
+	}
+	sort.Strings(fileNames)
+	for _, rel := range fileNames {
+		fi := remoteFiles[rel]
+		local, err := localJoin(localDir, rel)
+		if err != nil {
+			return report, err
+		}
+		remote := src.remoteEntry(fi)
+		if info, err := os.Stat(local); err == nil && !info.IsDir() {
+			changed, err := opts.changed(remote, syncEntry{
+				size:    info.Size(),
+				modTime: info.ModTime,
+				sum: func() (string, error) {
+					f, err := os.Open(local)
+					if err != nil {
+						return "", err
+					}
+					defer f.Close()
+					return md5Sum(f)
+				},
+			})
+			if err != nil {
+				return report, err
+			}
+			if !changed {
+				report.Unchanged = append(report.Unchanged, rel)
+				continue
+			}
+		}
+		report.Copied = append(report.Copied, rel)
+		if opts.DryRun {
+			continue
+		}
+		// Files written without their parent markers have no directory
+		// listed.
+		if err := os.MkdirAll(filepath.Dir(local), fs.ModePerm); err != nil {
+			return report, err
+		}
+		modTime := remote.modTime()
+		if err := src.download(fi.info.Key, local, fi.Mode().Perm(), modTime); err != nil {
+			return report, err
+		}
+	}
+	if !opts.Delete {
+		return report, nil
+	}
+
+	var extraneous, localDirs []string
+	// Directories holding files that are kept, such as excluded files, are
+	// kept too.
+	kept := make(map[string]bool)
+	err = filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			localDirs = append(localDirs, rel)
+			return nil
+		}
+		if _, ok := remoteFiles[rel]; !ok && opts.match(rel) {
+			extraneous = append(extraneous, rel)
+			return nil
+		}
+		for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+			kept[d] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	for _, rel := range localDirs {
+		if _, ok := remoteDirs[rel]; !ok && !kept[rel] {
+			extraneous = append(extraneous, rel)
+		}
+	}
+	// Remove the deepest paths first so directories are empty when removed.
+	sort.Sort(sort.Reverse(sort.StringSlice(extraneous)))
+	for _, rel := range extraneous {
+		if !opts.DryRun {
+			if err := os.Remove(filepath.Join(localDir, filepath.FromSlash(rel))); err != nil {
+				return report, err
+			}
+		}
+		report.Deleted = append(report.Deleted, rel)
+	}
+	return report, nil
+}
+
+// localJoin returns the path of rel, a path derived from a bucket key, inside
+// localDir. Keys written by other clients can hold ".." or empty elements
+// that would escape localDir, so they are rejected.
+func localJoin(localDir, rel string) (string, error) {
+	p := filepath.FromSlash(rel)
+	if !fs.ValidPath(rel) || !filepath.IsLocal(p) {
+		return "", &fs.PathError{Op: "open", Path: rel, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(localDir, p), nil
+}
+
+// download writes the object at key to the local file at name.
+func (fss3 *FSS3) download(key, name string, perm fs.FileMode, modTime time.Time) error {
+	obj, err := fss3.getObject(key, nil)
+	if err != nil {
+		return minioErrToPathErr(err)
+	}
+	defer obj.Close()
+	// The checksum is read from the response the contents come with.
+	info, err := obj.Stat()
+	if err != nil {
+		return minioErrToPathErr(err)
+	}
+	if perm == 0 {
+		perm = 0644
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, fss3.verify(obj, &info))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(name, perm); err != nil {
+		return err
+	}
+	return os.Chtimes(name, modTime, modTime)
+}
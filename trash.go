@@ -0,0 +1,228 @@
+package fss3
+
+import (
+	"context"
+	"io/fs"
+	"iter"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metadata recorded on the objects moved to the trash. The path is escaped
+// since metadata values are sent as HTTP headers.
+const (
+	trashPathMeta    = "fss3-trash-path"
+	trashDeletedMeta = "fss3-trash-deleted"
+)
+
+// TrashItem is a deletion recorded in the trash by Remove or RemoveAll.
+type TrashItem struct {
+	// ID identifies the item for RestoreTrash.
+	ID string
+	// Path is the path of the deleted file or directory.
+	Path    string
+	Deleted time.Time
+	// Files and Size are the number and total size of the objects deleted.
+	Files int
+	Size  int64
+}
+
+// trashed reports whether deletes done with ropts move objects to the trash.
+func (fss3 *FSS3) trashed(ropts *RemoveOptions) bool {
+	return fss3.cfg.Trash && !ropts.Permanent && !ropts.AllVersions
+}
+
+// trashPrefix returns the key prefix of the trash item id, or of the trash
+// when id is empty.
+func (fss3 *FSS3) trashPrefix(id string) string {
+	prefix := fss3.dirPrefix(sanitizeName(fss3.cfg.TrashPrefix))
+	if id == "" {
+		return prefix
+	}
+	return prefix + id + "/"
+}
+
+// moveToTrash moves the objects at keys, the contents of the file or
+// directory name, to a new trash item. progress, if not nil, is called after
+// each object with the number of objects moved and failed so far.
+func (fss3 *FSS3) moveToTrash(name string, keys []string, progress func(moved, failed int)) error {
+	deleted := time.Now().UTC()
+	id, err := newID(deleted)
+	if err != nil {
+		return err
+	}
+	prefix := fss3.trashPrefix(id)
+	var mu sync.Mutex
+	var moved, failed int
+	return forEach(keys, fss3.cfg.Concurrency, func(key string) error {
+		extra := map[string]string{
+			trashPathMeta:    url.PathEscape(name),
+			trashDeletedMeta: deleted.Format(time.RFC3339Nano),
+		}
+		err := fss3.moveObject(key, prefix+key, extra)
+		if progress != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+			} else {
+				moved++
+			}
+			progress(moved, failed)
+		}
+		return err
+	})
+}
+
+// moveObject moves the object at src to dst, adding the metadata extra and
+// dropping the keys of extra that are set to an empty string.
+func (fss3 *FSS3) moveObject(src, dst string, extra map[string]string) error {
+	// The metadata is read as stored, without normalizing it for the layout.
+	info, err := fss3.client.StatObject(context.Background(), fss3.cfg.BucketName, src, statObjectOptions{})
+	if err != nil {
+		return minioErrToPathErr(err)
+	}
+	meta := make(map[string]string, len(info.UserMetadata)+len(extra)+1)
+	for k, v := range info.UserMetadata {
+		meta[k] = v
+	}
+	for k, v := range extra {
+		delete(meta, http.CanonicalHeaderKey(k))
+		if v != "" {
+			meta[k] = v
+		}
+	}
+	if info.ContentType != "" {
+		meta["Content-Type"] = info.ContentType
+	}
+	srcOpts := copySrcOptions{Object: src, MatchETag: info.ETag}
+	dstOpts := copyDestOptions{ReplaceMetadata: true, UserMetadata: meta}
+	if _, err := fss3.copyObjectSize(src, dst, info.Size, &srcOpts, &dstOpts); err != nil {
+		return minioErrToPathErr(err)
+	}
+	if err := fss3.removeObject(src, nil); err != nil {
+		return minioErrToPathErr(err)
+	}
+	return nil
+}
+
+// ListTrash returns the items in the trash, oldest first.
+func (fss3 *FSS3) ListTrash() ([]TrashItem, error) {
+	prefix := fss3.trashPrefix("")
+	opts := listObjectsOptions{Prefix: prefix, Recursive: true}
+	var items []TrashItem
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return nil, minioErrToPathErr(obj.Err)
+		}
+		id, _, ok := strings.Cut(strings.TrimPrefix(obj.Key, prefix), "/")
+		if !ok {
+			continue
+		}
+		if len(items) == 0 || items[len(items)-1].ID != id {
+			item, err := fss3.trashItem(id, obj.Key)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		items[len(items)-1].Files++
+		items[len(items)-1].Size += obj.Size
+	}
+	return items, nil
+}
+
+// trashItem returns the trash item id from the metadata of its object at
+// key.
+func (fss3 *FSS3) trashItem(id, key string) (TrashItem, error) {
+	info, err := fss3.client.StatObject(context.Background(), fss3.cfg.BucketName, key, statObjectOptions{})
+	if err != nil {
+		return TrashItem{}, minioErrToPathErr(err)
+	}
+	item := TrashItem{ID: id}
+	item.Path, err = url.PathUnescape(info.UserMetadata[http.CanonicalHeaderKey(trashPathMeta)])
+	if err != nil {
+		return TrashItem{}, &fs.PathError{Op: "trash", Path: id, Err: err}
+	}
+	item.Deleted, _ = time.Parse(time.RFC3339Nano, info.UserMetadata[http.CanonicalHeaderKey(trashDeletedMeta)])
+	return item, nil
+}
+
+// trashKeys returns the keys of the objects of the trash item id.
+func (fss3 *FSS3) trashKeys(id string) ([]string, error) {
+	opts := listObjectsOptions{Prefix: fss3.trashPrefix(id), Recursive: true}
+	var keys []string
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return nil, minioErrToPathErr(obj.Err)
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// RestoreTrash moves the objects of the trash item id back to where they
+// were deleted from. It fails with fs.ErrExist if a file or directory was
+// created at that path since.
+func (fss3 *FSS3) RestoreTrash(id string) error {
+	keys, err := fss3.trashKeys(id)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return &fs.PathError{Op: "restore", Path: id, Err: fs.ErrNotExist}
+	}
+	item, err := fss3.trashItem(id, keys[0])
+	if err != nil {
+		return err
+	}
+	if _, err := fss3.Stat(item.Path); err == nil && item.Path != rootName {
+		return &fs.PathError{Op: "restore", Path: item.Path, Err: fs.ErrExist}
+	}
+	if err := fss3.mkdirParents(sanitizeName(path.Dir(item.Path))); err != nil {
+		return err
+	}
+	prefix := fss3.trashPrefix(id)
+	drop := map[string]string{trashPathMeta: "", trashDeletedMeta: ""}
+	err = forEach(keys, fss3.cfg.Concurrency, func(key string) error {
+		return fss3.moveObject(key, strings.TrimPrefix(key, prefix), drop)
+	})
+	fss3.meta.invalidateTree(item.Path)
+	return err
+}
+
+// EmptyTrash permanently removes the items deleted more than olderThan ago.
+// Zero empties the whole trash. The objects are removed in batches, like
+// RemoveAll does.
+func (fss3 *FSS3) EmptyTrash(olderThan time.Duration) error {
+	items, err := fss3.ListTrash()
+	if err != nil {
+		return err
+	}
+	return fss3.removeBatched(fss3.expiredTrash(items, olderThan), nil)
+}
+
+// expiredTrash returns an iterator over the objects of the items deleted
+// more than olderThan ago. Listing errors are yielded as entries with Err
+// set.
+func (fss3 *FSS3) expiredTrash(items []TrashItem, olderThan time.Duration) iter.Seq[objectInfo] {
+	return func(yield func(objectInfo) bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for _, item := range items {
+			if time.Since(item.Deleted) < olderThan {
+				continue
+			}
+			opts := listObjectsOptions{Prefix: fss3.trashPrefix(item.ID), Recursive: true}
+			for obj := range fss3.listObjectsContext(ctx, &opts) {
+				if !yield(obj) {
+					return
+				}
+			}
+		}
+	}
+}
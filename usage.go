@@ -0,0 +1,165 @@
+package fss3
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// UsageTotal is the number and total size of a set of files.
+type UsageTotal struct {
+	Size  int64
+	Count int64
+}
+
+func (t *UsageTotal) add(size int64) {
+	t.Size += size
+	t.Count++
+}
+
+// Usage is the disk usage of a directory. Directory markers aren't counted.
+type Usage struct {
+	// Path is the path of the file or directory.
+	Path string
+	UsageTotal
+	// ByStorageClass breaks the total down by storage class.
+	ByStorageClass map[string]UsageTotal
+	// Children holds the usage of the entries of a directory, sorted by
+	// path, down to the depth passed to FSS3.Usage.
+	Children []*Usage
+}
+
+func newUsage(name string) *Usage {
+	return &Usage{Path: name, ByStorageClass: make(map[string]UsageTotal)}
+}
+
+// add counts the object described by info.
+func (u *Usage) add(info *objectInfo) {
+	u.UsageTotal.add(info.Size)
+	class := info.StorageClass
+	if class == "" {
+		class = "STANDARD"
+	}
+	t := u.ByStorageClass[class]
+	t.add(info.Size)
+	u.ByStorageClass[class] = t
+}
+
+// merge adds the totals of c to u.
+func (u *Usage) merge(c *Usage) {
+	u.Size += c.Size
+	u.Count += c.Count
+	for class, ct := range c.ByStorageClass {
+		t := u.ByStorageClass[class]
+		t.Size += ct.Size
+		t.Count += ct.Count
+		u.ByStorageClass[class] = t
+	}
+}
+
+// Usage returns the disk usage of the directory dir. The usage of its
+// entries is broken down depth levels deep; zero only returns the total.
+// Subdirectories are measured concurrently, with up to Config.Concurrency
+// recursive listings.
+func (fss3 *FSS3) Usage(dir string, depth int) (*Usage, error) {
+	dir = sanitizeName(dir)
+	if _, err := fss3.statDir(dir); err != nil {
+		return nil, minioErrToPathErr(err)
+	}
+	u := newUsage(dir)
+	var subdirs []*Usage
+	opts := listObjectsOptions{Prefix: fss3.dirPrefix(dir)}
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return nil, minioErrToPathErr(obj.Err)
+		}
+		// With MarkerSlash, the listing includes the marker of dir itself.
+		if name, ok := fss3.markerDir(obj.Key); ok && name == dir {
+			continue
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			subdirs = append(subdirs, newUsage(fss3.keyToName(obj.Key)))
+			continue
+		}
+		if _, ok := fss3.markerDir(obj.Key); ok {
+			continue
+		}
+		u.add(&obj)
+		if depth > 0 {
+			c := newUsage(fss3.keyToName(obj.Key))
+			c.add(&obj)
+			u.Children = append(u.Children, c)
+		}
+	}
+
+	err := forEach(subdirs, fss3.cfg.Concurrency, func(c *Usage) error {
+		return fss3.dirUsage(c, depth-1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range subdirs {
+		u.merge(c)
+		if depth > 0 {
+			u.Children = append(u.Children, c)
+		}
+	}
+	sortUsage(u)
+	return u, nil
+}
+
+// dirUsage fills in the usage of the directory u with a recursive listing,
+// breaking it down depth levels deep.
+func (fss3 *FSS3) dirUsage(u *Usage, depth int) error {
+	nodes := map[string]*Usage{u.Path: u}
+	// node returns the usage of name, creating its parents as needed.
+	var node func(name string) *Usage
+	node = func(name string) *Usage {
+		if n, ok := nodes[name]; ok {
+			return n
+		}
+		n := newUsage(name)
+		nodes[name] = n
+		parent := node(path.Dir(name))
+		parent.Children = append(parent.Children, n)
+		return n
+	}
+
+	opts := listObjectsOptions{
+		Prefix:    fss3.dirPrefix(u.Path),
+		Recursive: true,
+	}
+	for obj := range fss3.listObjects(&opts) {
+		if obj.Err != nil {
+			return minioErrToPathErr(obj.Err)
+		}
+		if _, ok := fss3.markerDir(obj.Key); ok {
+			continue
+		}
+		u.add(&obj)
+		if depth <= 0 {
+			continue
+		}
+		// Count the object in the entries of u down to depth.
+		rel := strings.Split(strings.TrimPrefix(fss3.keyToName(obj.Key), u.Path+"/"), "/")
+		if len(rel) > depth {
+			rel = rel[:depth]
+		}
+		name := u.Path
+		for _, elem := range rel {
+			name += "/" + elem
+			node(name).add(&obj)
+		}
+	}
+	return nil
+}
+
+// sortUsage sorts the children of u and of its descendants by path.
+func sortUsage(u *Usage) {
+	sort.Slice(u.Children, func(i, j int) bool {
+		return u.Children[i].Path < u.Children[j].Path
+	})
+	for _, c := range u.Children {
+		sortUsage(c)
+	}
+}
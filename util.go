@@ -1,33 +1,44 @@
 package fss3
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io/fs"
 	"mime"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 )
 
 func sanitizeName(name string) string {
-	name = strings.ReplaceAll(name, dirFileName, ".")
 	name = strings.Trim(name, "/")
 	name = filepath.Clean(name)
 	if name == "." {
-		return dirFileName
+		return rootName
 	}
 	return name
 }
 
-func keyBaseName(key string) string {
-	name := sanitizeName(key)
-	return filepath.Base(name)
-}
-
 func errToRspErr(err error) minio.ErrorResponse {
 	return minio.ToErrorResponse(err)
 }
 
+// isNotExist reports whether err means that the object doesn't exist.
+func isNotExist(err error) bool {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return err != nil && errToRspErr(err).Code == "NoSuchKey"
+}
+
 func minioErrToPathErr(err error) *fs.PathError {
 	rspErr := errToRspErr(err)
 	return &fs.PathError{
@@ -45,6 +56,154 @@ func guessContentType(name string) string {
 	return contentType
 }
 
+// knownDir reports whether the directory is known to exist.
+func (fss3 *FSS3) knownDir(name string) bool {
+	fss3.dirsMu.Lock()
+	defer fss3.dirsMu.Unlock()
+	return fss3.dirs[name]
+}
+
+// rememberDir records that the directory exists.
+func (fss3 *FSS3) rememberDir(name string) {
+	fss3.dirsMu.Lock()
+	defer fss3.dirsMu.Unlock()
+	fss3.dirs[name] = true
+}
+
+// forgetDirs forgets the directory and all the directories it contains.
+func (fss3 *FSS3) forgetDirs(name string) {
+	fss3.dirsMu.Lock()
+	defer fss3.dirsMu.Unlock()
+	for dir := range fss3.dirs {
+		if dir == name || name == rootName || strings.HasPrefix(dir, name+"/") {
+			delete(fss3.dirs, dir)
+		}
+	}
+}
+
+// joinName returns the path of rel inside the directory dir.
+func (fss3 *FSS3) joinName(dir, rel string) string {
+	if dir == rootName {
+		return rel
+	}
+	return dir + "/" + rel
+}
+
+// relPath returns p, a path yielded by walking root, relative to root.
+func relPath(root, p string) string {
+	if root == "." {
+		return p
+	}
+	if p == root {
+		return "."
+	}
+	return strings.TrimPrefix(p, root+"/")
+}
+
+// formatModTime formats t as fractional Unix seconds, the format used by the
+// "mtime" metadata.
+func formatModTime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// parseModTime parses a time formatted by formatModTime.
+// Whole seconds are accepted too.
+func parseModTime(s string) (time.Time, error) {
+	sec, frac, _ := strings.Cut(s, ".")
+	secs, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nsecs int64
+	if frac != "" {
+		frac = (frac + "000000000")[:9]
+		nsecs, err = strconv.ParseInt(frac, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(secs, nsecs), nil
+}
+
 func umask(mask int, mode fs.FileMode) fs.FileMode {
 	return mode - fs.FileMode(mask)
 }
+
+// dirPrefix returns the key prefix of the objects inside the given directory.
+func (fss3 *FSS3) dirPrefix(name string) string {
+	if name == rootName {
+		return ""
+	}
+	return fss3.nameToKey(name) + "/"
+}
+
+// newID returns a unique ID starting with t, so IDs sort by time.
+func newID(t time.Time) (string, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return t.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b[:]), nil
+}
+
+// forEach calls fn for every item using at most n goroutines.
+// It returns the errors of all the failed calls joined together.
+func forEach[T any](items []T, n int, fn func(T) error) error {
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	itemsCh := make(chan T)
+	for i := 0; i < n && i < len(items); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range itemsCh {
+				if err := fn(item); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, item := range items {
+		itemsCh <- item
+	}
+	close(itemsCh)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// forEachContext calls fn for every item using at most n goroutines, until
+// ctx is done or a call fails. It returns the first error.
+func forEachContext[T any](ctx context.Context, items []T, n int, fn func(T) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var wg sync.WaitGroup
+	itemsCh := make(chan T)
+	for i := 0; i < n && i < len(items); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range itemsCh {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := fn(item); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+loop:
+	for _, item := range items {
+		select {
+		case itemsCh <- item:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(itemsCh)
+	wg.Wait()
+	return context.Cause(ctx)
+}
@@ -0,0 +1,120 @@
+package fss3
+
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
+// Version describes a version of an object in a versioned bucket.
+type Version struct {
+	// ID is the version ID.
+	ID           string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	// IsLatest reports whether this is the current version.
+	IsLatest bool
+	// IsDeleteMarker reports whether this version marks the object as
+	// deleted.
+	IsDeleteMarker bool
+}
+
+// VersionID returns the version ID of the object, which is empty in buckets
+// without versioning.
+func (fi *FileInfo) VersionID() string {
+	return fi.info.VersionID
+}
+
+// ListVersions returns the versions of the named object, including delete
+// markers, newest first.
+func (fss3 *FSS3) ListVersions(name string) ([]Version, error) {
+	name = sanitizeName(name)
+	key := fss3.nameToKey(name)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := listObjectsOptions{
+		Prefix:       key,
+		Recursive:    true,
+		WithVersions: true,
+	}
+	var versions []Version
+	for obj := range fss3.listObjectsContext(ctx, &opts) {
+		if obj.Err != nil {
+			return nil, minioErrToPathErr(obj.Err)
+		}
+		if obj.Key < key {
+			continue
+		}
+		if obj.Key > key {
+			break
+		}
+		versions = append(versions, Version{
+			ID:             obj.VersionID,
+			Size:           obj.Size,
+			ETag:           obj.ETag,
+			LastModified:   obj.LastModified,
+			IsLatest:       obj.IsLatest,
+			IsDeleteMarker: obj.IsDeleteMarker,
+		})
+	}
+	if len(versions) == 0 {
+		return nil, &fs.PathError{Op: "versions", Path: name, Err: fs.ErrNotExist}
+	}
+	return versions, nil
+}
+
+// StatVersion returns a fs.FileInfo describing a version of the named
+// object.
+func (fss3 *FSS3) StatVersion(name, versionID string) (fs.FileInfo, error) {
+	name = sanitizeName(name)
+	info, err := fss3.statObject(fss3.nameToKey(name), &statObjectOptions{VersionID: versionID})
+	if err != nil {
+		return nil, minioErrToPathErr(err)
+	}
+	return fss3.newFileInfo(info), nil
+}
+
+// OpenVersion opens a version of the named object for reading.
+func (fss3 *FSS3) OpenVersion(name, versionID string) (*File, error) {
+	name = sanitizeName(name)
+	key := fss3.nameToKey(name)
+	info, err := fss3.statObject(key, &statObjectOptions{VersionID: versionID})
+	if err != nil {
+		return nil, minioErrToPathErr(err)
+	}
+	obj, err := fss3.getObject(key, &getObjectOptions{VersionID: versionID})
+	if err != nil {
+		return nil, minioErrToPathErr(err)
+	}
+	return &File{
+		fs:       &FS{fss3: fss3},
+		name:     name,
+		obj:      fss3.verify(obj, &info),
+		fileInfo: fss3.newFileInfo(info),
+	}, nil
+}
+
+// RestoreVersion makes a version of the named object its current version,
+// by copying it server-side.
+func (fss3 *FSS3) RestoreVersion(name, versionID string) error {
+	name = sanitizeName(name)
+	key := fss3.nameToKey(name)
+	src := copySrcOptions{Object: key, VersionID: versionID}
+	_, err := fss3.copyObject(key, key, &src, nil)
+	if err != nil {
+		return minioErrToPathErr(err)
+	}
+	return nil
+}
+
+// RemoveVersion permanently removes a version of the named object. Removing
+// a delete marker undeletes the object.
+func (fss3 *FSS3) RemoveVersion(name, versionID string) error {
+	name = sanitizeName(name)
+	err := fss3.removeObject(fss3.nameToKey(name), &removeObjectOptions{VersionID: versionID})
+	if err != nil {
+		return minioErrToPathErr(err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package fss3
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, in lexical order.
+//
+// Unlike fs.WalkDir, the tree is read with a single recursive listing. The
+// contract of fn is the one of fs.WalkDirFunc, including fs.SkipDir and
+// fs.SkipAll.
+func (fss3 *FSS3) WalkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := fss3.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = fss3.walkDir(root, &DirEntry{info: info.(*FileInfo)}, fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (fss3 *FSS3) walkDir(root string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(root, d, nil); err != nil || !d.IsDir() {
+		return err
+	}
+	name := sanitizeName(root)
+	files, dirs, err := fss3.remoteEntries(name)
+	if err != nil {
+		return fn(root, d, err)
+	}
+
+	// Rebuild the tree, including the directories that only exist as the
+	// prefix of other keys.
+	var rels []string
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	for rel := range dirs {
+		rels = append(rels, rel)
+	}
+	for _, rel := range rels {
+		for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+			if _, ok := dirs[d]; !ok {
+				dirs[d] = fss3.newFileInfo(objectInfo{Key: fss3.dirPrefix(name) + fss3.nameToKey(d) + "/"})
+			}
+		}
+	}
+	children := make(map[string][]*Entry)
+	add := func(rel string, fi *FileInfo, dir bool) {
+		parent := path.Dir(rel)
+		children[parent] = append(children[parent], &Entry{
+			fss3: fss3,
+			name: fss3.joinName(name, rel),
+			info: fi,
+			dir:  dir,
+		})
+	}
+	for rel, fi := range files {
+		add(rel, fi, false)
+	}
+	for rel, fi := range dirs {
+		add(rel, fi, true)
+	}
+	for _, ents := range children {
+		sort.Slice(ents, func(i, j int) bool {
+			return ents[i].Name() < ents[j].Name()
+		})
+	}
+
+	var walk func(dir, p string) error
+	walk = func(dir, p string) error {
+		for _, ent := range children[dir] {
+			rel := path.Join(dir, ent.Name())
+			err := fn(path.Join(p, ent.Name()), ent, nil)
+			if err == nil && ent.dir {
+				err = walk(rel, path.Join(p, ent.Name()))
+			}
+			if err != nil {
+				if err == fs.SkipDir {
+					if ent.dir {
+						continue
+					}
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(".", root)
+}